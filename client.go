@@ -0,0 +1,20 @@
+package redgiant
+
+// Client reads data from an inverter, either directly as Redgiant does or
+// through a server as http.Redgiant does. Implementations can be checked with
+// redgianttest.TestClient.
+type Client interface {
+	About() (About, error)
+	State() (State, error)
+	Devices() ([]Device, error)
+	// RealData and DirectData return the measurements of the device localized
+	// in the language. Without services, all services of the device type are
+	// queried and unavailable ones are skipped.
+	RealData(deviceID int, lang Language, services ...string) ([]RealMeasurement, error)
+	DirectData(deviceID int, lang Language, services ...string) ([]DirectMeasurement, error)
+	// Snapshot reads all of the above in one pass. Services that cannot be
+	// read are reported per device.
+	Snapshot(lang Language) (Snapshot, error)
+}
+
+var _ Client = (*Redgiant)(nil)
@@ -0,0 +1,13 @@
+package redgiant_test
+
+import (
+	"testing"
+
+	"github.com/pmeier/redgiant/redgianttest"
+)
+
+func TestRedgiantClient(t *testing.T) {
+	rg, inv := redgianttest.NewRedgiant(t)
+
+	redgianttest.TestClient(t, rg, inv)
+}
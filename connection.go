@@ -0,0 +1,214 @@
+package redgiant
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pmeier/redgiant/internal/errors"
+)
+
+type ConnectionMode uint8
+
+const (
+	// PersistentConnectionMode keeps the connection to the inverter open at all times.
+	PersistentConnectionMode ConnectionMode = iota
+	// OnDemandConnectionMode connects on the first request and disconnects
+	// after an idle period, so the web UI and the app of the inverter can log in.
+	OnDemandConnectionMode
+)
+
+func (m ConnectionMode) String() string {
+	switch m {
+	case PersistentConnectionMode:
+		return "persistent"
+	case OnDemandConnectionMode:
+		return "on-demand"
+	}
+	return strconv.Itoa(int(m))
+}
+
+func ParseConnectionMode(modeStr string) (ConnectionMode, error) {
+	for _, mode := range []ConnectionMode{
+		PersistentConnectionMode,
+		OnDemandConnectionMode,
+	} {
+		if strings.EqualFold(modeStr, mode.String()) {
+			return mode, nil
+		}
+	}
+	return PersistentConnectionMode, errors.New(
+		"unknown connection mode",
+		errors.WithContext(errors.Context{"mode": modeStr}),
+	)
+}
+
+type ConnectionState struct {
+	Connected bool `json:"connected"`
+	// Since is the time of the last successful connection or, if not
+	// connected, the time the connection was found to be lost.
+	Since       time.Time `json:"since"`
+	LastError   string    `json:"lastError,omitempty"`
+	NextAttempt time.Time `json:"nextAttempt,omitempty"`
+	// Idle reports that the connection was closed on purpose, i.e. it is
+	// released or unused in the on-demand mode.
+	Idle bool `json:"idle,omitempty"`
+	// ReleasedUntil is set while the session is yielded to other clients.
+	ReleasedUntil time.Time `json:"releasedUntil,omitempty"`
+	// LastHeartbeat and LastRead are the times the inverter last answered a
+	// heartbeat and a data request respectively.
+	LastHeartbeat time.Time `json:"lastHeartbeat,omitempty"`
+	LastRead      time.Time `json:"lastRead,omitempty"`
+}
+
+func (rg *Redgiant) Connect() error {
+	err := rg.sg.Connect()
+	rg.updateState(err, time.Time{})
+	return err
+}
+
+func (rg *Redgiant) updateState(err error, nextAttempt time.Time) {
+	rg.stateMu.Lock()
+	defer rg.stateMu.Unlock()
+
+	connected := err == nil && rg.sg.IsConnected()
+	if connected != rg.state.Connected || rg.state.Since.IsZero() {
+		rg.state.Since = time.Now()
+		// the firmware might have been updated while disconnected
+		rg.about.Store(nil)
+	}
+	rg.state.Connected = connected
+	rg.state.NextAttempt = nextAttempt
+	if err != nil {
+		rg.state.LastError = err.Error()
+	} else if connected {
+		rg.state.LastError = ""
+	}
+}
+
+func (rg *Redgiant) ConnectionState() ConnectionState {
+	rg.stateMu.Lock()
+	defer rg.stateMu.Unlock()
+
+	// the connection might have been lost without KeepConnected noticing yet
+	if rg.state.Connected && !rg.sg.IsConnected() {
+		rg.state.Connected = false
+		rg.state.Since = time.Now()
+	}
+	s := rg.state
+	if time.Now().Before(rg.releasedUntil) {
+		s.ReleasedUntil = rg.releasedUntil
+		s.NextAttempt = rg.releasedUntil
+		s.Idle = !s.Connected
+	} else if rg.connectionMode == OnDemandConnectionMode {
+		s.Idle = !s.Connected && s.LastError == ""
+	}
+	s.LastHeartbeat = rg.sg.LastHeartbeat()
+	s.LastRead = rg.sg.LastRead()
+	return s
+}
+
+func (rg *Redgiant) released() (time.Time, bool) {
+	rg.stateMu.Lock()
+	defer rg.stateMu.Unlock()
+	return rg.releasedUntil, time.Now().Before(rg.releasedUntil)
+}
+
+// Release closes the connection and refrains from connecting again for the
+// given duration, so the web UI or the app of the inverter can log in.
+// Requests made in the meantime fail with a SungrowDisconnectedError. A
+// non-positive duration ends a previous release early.
+func (rg *Redgiant) Release(d time.Duration) time.Time {
+	rg.log.Trace().Dur("d", d).Msg("Redgiant.Release()")
+
+	rg.stateMu.Lock()
+	rg.releasedUntil = time.Now().Add(max(d, 0))
+	until := rg.releasedUntil
+	rg.stateMu.Unlock()
+
+	if d > 0 {
+		rg.log.Info().Time("until", until).Msg("releasing session")
+		rg.sg.Close()
+		rg.updateState(nil, until)
+	} else {
+		rg.log.Info().Msg("reclaiming session")
+	}
+
+	// KeepConnected waits for the end of the previous release otherwise
+	select {
+	case rg.wake <- struct{}{}:
+	default:
+	}
+	return until
+}
+
+// acquire is called before every request to the inverter. It connects on
+// demand and fails while the session is released.
+func (rg *Redgiant) acquire() error {
+	rg.lastUse.Store(time.Now().UnixNano())
+
+	if _, ok := rg.released(); ok {
+		return newSungrowDisconnectedError("session released")
+	}
+	if rg.connectionMode == OnDemandConnectionMode && !rg.sg.IsConnected() {
+		if err := rg.Connect(); err != nil {
+			return wrapSungrowDisconnectedError(err)
+		}
+	}
+	return nil
+}
+
+// KeepConnected manages the connection to the inverter until ctx is done. In
+// the persistent mode, it connects and reconnects whenever the connection is
+// lost. Failed attempts are retried with exponential backoff between
+// minBackoff and maxBackoff. In the on-demand mode, it disconnects once the
+// connection was idle for the configured period.
+func (rg *Redgiant) KeepConnected(ctx context.Context, minBackoff time.Duration, maxBackoff time.Duration) {
+	backoff := minBackoff
+	for {
+		var wait time.Duration
+		if until, ok := rg.released(); ok {
+			wait = time.Until(until)
+		} else if rg.connectionMode == OnDemandConnectionMode {
+			wait = rg.closeIfIdle()
+		} else if rg.sg.IsConnected() {
+			backoff = minBackoff
+			wait = minBackoff
+		} else if err := rg.sg.Connect(); err != nil {
+			rg.log.Warn().Err(err).Dur("retry", backoff).Msg("unable to connect to inverter")
+			rg.updateState(err, time.Now().Add(backoff))
+			wait = backoff
+			backoff = min(2*backoff, maxBackoff)
+		} else {
+			rg.updateState(nil, time.Time{})
+			backoff = minBackoff
+			wait = minBackoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-rg.wake:
+		case <-time.After(wait):
+		}
+	}
+}
+
+// closeIfIdle closes the connection if it was not used for the idle timeout
+// and returns the time until the next check.
+func (rg *Redgiant) closeIfIdle() time.Duration {
+	if !rg.sg.IsConnected() {
+		return rg.idleTimeout
+	}
+
+	idle := time.Since(time.Unix(0, rg.lastUse.Load()))
+	if idle < rg.idleTimeout {
+		return rg.idleTimeout - idle
+	}
+
+	rg.log.Info().Dur("idle", idle).Msg("closing idle connection")
+	rg.sg.Close()
+	rg.updateState(nil, time.Time{})
+	return rg.idleTimeout
+}
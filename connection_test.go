@@ -0,0 +1,34 @@
+package redgiant_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/redgianttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnDemandConnection(t *testing.T) {
+	rg, _ := redgianttest.NewRedgiant(t, redgiant.WithConnectionMode(redgiant.OnDemandConnectionMode, 50*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go rg.KeepConnected(ctx, 10*time.Millisecond, 50*time.Millisecond)
+
+	_, err := rg.State()
+	require.NoError(t, err)
+	assert.True(t, rg.IsConnected())
+
+	// the unused connection is closed after the idle timeout
+	assert.Eventually(t, func() bool { return !rg.IsConnected() }, 2*time.Second, 10*time.Millisecond)
+	s := rg.ConnectionState()
+	assert.True(t, s.Idle)
+	assert.Empty(t, s.LastError)
+
+	// and opened again by the next request
+	_, err = rg.State()
+	require.NoError(t, err)
+	assert.True(t, rg.IsConnected())
+}
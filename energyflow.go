@@ -0,0 +1,277 @@
+package redgiant
+
+import (
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pmeier/redgiant/internal/errors"
+)
+
+// I18N codes of the real data of hybrid inverters the energy flow is derived
+// from. All powers are reported as non-negative values.
+const (
+	PVPowerI18NCode                 = "I18N_COMMON_TOTAL_DCPOWER"
+	LoadPowerI18NCode               = "I18N_COMMON_LOAD_TOTAL_ACTIVE_POWER"
+	FeedInPowerI18NCode             = "I18N_COMMON_FEED_NETWORK_TOTAL_ACTIVE_POWER"
+	PurchasedPowerI18NCode          = "I18N_CONFIG_KEY_4060"
+	BatteryChargingPowerI18NCode    = "I18N_CONFIG_KEY_3907"
+	BatteryDischargingPowerI18NCode = "I18N_CONFIG_KEY_3921"
+	BatterySOCI18NCode              = "I18N_COMMON_BATTERY_SOC"
+)
+
+// hybridInverterDeviceType is the type of the devices that provide the
+// energy flow. Their battery data is read with the real_battery service.
+const hybridInverterDeviceType = 35
+
+// EnergyNodeID identifies a node of the energy flow.
+type EnergyNodeID string
+
+const (
+	PVNode      EnergyNodeID = "pv"
+	BatteryNode EnergyNodeID = "battery"
+	GridNode    EnergyNodeID = "grid"
+	HouseNode   EnergyNodeID = "house"
+)
+
+// EnergyMetrics are derived from the real data of a hybrid inverter. Powers
+// are in kW.
+type EnergyMetrics struct {
+	PVPower          float64 `json:"pvPower"`
+	HouseConsumption float64 `json:"houseConsumption"`
+	// BatteryNetPower is positive while charging and negative while
+	// discharging.
+	BatteryNetPower float64 `json:"batteryNetPower"`
+	// GridNetPower is positive while purchasing and negative while feeding in.
+	GridNetPower float64 `json:"gridNetPower"`
+	// SelfConsumption is the share of the PV power used on site. It is nil
+	// without PV power.
+	SelfConsumption *float64 `json:"selfConsumption"`
+	// Autarky is the share of the house consumption not covered by the grid.
+	// It is nil without consumption.
+	Autarky     *float64 `json:"autarky"`
+	PVToHouse   float64  `json:"pvToHouse"`
+	PVToBattery float64  `json:"pvToBattery"`
+	PVToGrid    float64  `json:"pvToGrid"`
+}
+
+// EnergyNode is a source or sink of power. Power is the production of the PV,
+// the net power of the battery and the grid as in EnergyMetrics and the
+// consumption of the house.
+type EnergyNode struct {
+	ID    EnergyNodeID `json:"id"`
+	Power float64      `json:"power"`
+	// SOC is the state of charge of the battery in percent, if known.
+	SOC *float64 `json:"soc,omitempty"`
+}
+
+// EnergyEdge is the power flowing from one node to another. Every possible
+// edge is included, even without power, so diagrams keep their layout.
+type EnergyEdge struct {
+	From  EnergyNodeID `json:"from"`
+	To    EnergyNodeID `json:"to"`
+	Power float64      `json:"power"`
+}
+
+// EnergyFlow is the power flow between the PV, the battery, the grid and the
+// house of a hybrid inverter.
+type EnergyFlow struct {
+	DeviceID int `json:"deviceID"`
+	// SampledAt is the time the latest measurement was read.
+	SampledAt time.Time     `json:"sampledAt"`
+	Unit      string        `json:"unit"`
+	Metrics   EnergyMetrics `json:"metrics"`
+	Nodes     []EnergyNode  `json:"nodes"`
+	Edges     []EnergyEdge  `json:"edges"`
+}
+
+// EnergyFlow derives the energy flow from the real data of a hybrid inverter
+// with a battery. The device ID 0 selects the first hybrid inverter.
+func (rg *Redgiant) EnergyFlow(deviceID int) (EnergyFlow, error) {
+	rg.log.Trace().Int("deviceID", deviceID).Msg("Redgiant.EnergyFlow()")
+
+	if err := rg.acquire(); err != nil {
+		return EnergyFlow{}, err
+	}
+
+	if deviceID == 0 {
+		devices, err := rg.Devices()
+		if err != nil {
+			return EnergyFlow{}, err
+		}
+		i := slices.IndexFunc(devices, func(d Device) bool { return d.Type == hybridInverterDeviceType })
+		if i < 0 {
+			return EnergyFlow{}, errors.New(
+				"no device supports energy flow",
+				errors.WithCode(errors.UnsupportedDeviceCode),
+				errors.WithHTTPCode(http.StatusUnprocessableEntity),
+				errors.WithFault(errors.ClientFault),
+			)
+		}
+		deviceID = devices[i].ID
+	}
+
+	info, err := rg.getDeviceInfo(deviceID)
+	if err != nil {
+		return EnergyFlow{}, err
+	}
+	if info.Type != hybridInverterDeviceType {
+		return EnergyFlow{}, newUnsupportedDeviceError(deviceID, info.Type)
+	}
+
+	ms, err := rg.RealData(deviceID, NoLanguage)
+	if err != nil {
+		return EnergyFlow{}, err
+	}
+	return NewEnergyFlow(deviceID, ms)
+}
+
+func newUnsupportedDeviceError(deviceID int, deviceType int) error {
+	return errors.New(
+		"device does not support energy flow",
+		errors.WithCode(errors.UnsupportedDeviceCode),
+		errors.WithContext(errors.Context{"deviceID": deviceID, "deviceType": deviceType}),
+		errors.WithHTTPCode(http.StatusUnprocessableEntity),
+		errors.WithFault(errors.ClientFault),
+		errors.WithHTTPDetail(errors.ContextHTTPDetail),
+		errors.WithHiddenFrames(2),
+	)
+}
+
+// NewEnergyFlow derives the energy flow from the real data of a hybrid
+// inverter, e.g. as read by RealData or Snapshot. The load power is optional,
+// without it the house consumption is the balance of the other powers.
+func NewEnergyFlow(deviceID int, ms []RealMeasurement) (EnergyFlow, error) {
+	f := EnergyFlow{DeviceID: deviceID, Unit: "kW"}
+
+	powers := map[string]float64{}
+	var soc *float64
+	for _, m := range ms {
+		if m.DeviceID != 0 && m.DeviceID != deviceID {
+			continue
+		}
+		switch m.I18NCode {
+		case PVPowerI18NCode, LoadPowerI18NCode, FeedInPowerI18NCode, PurchasedPowerI18NCode, BatteryChargingPowerI18NCode, BatteryDischargingPowerI18NCode:
+			p, err := parsePower(m)
+			if err != nil {
+				return EnergyFlow{}, err
+			}
+			powers[m.I18NCode] = p
+		case BatterySOCI18NCode:
+			if v, err := strconv.ParseFloat(m.Value, 64); err == nil {
+				soc = &v
+			}
+		default:
+			continue
+		}
+		if m.SampledAt.After(f.SampledAt) {
+			f.SampledAt = m.SampledAt
+		}
+	}
+
+	for _, code := range []string{PVPowerI18NCode, FeedInPowerI18NCode, PurchasedPowerI18NCode, BatteryChargingPowerI18NCode, BatteryDischargingPowerI18NCode} {
+		if _, ok := powers[code]; !ok {
+			return EnergyFlow{}, errors.New(
+				"missing measurement",
+				errors.WithCode(errors.MissingMeasurementCode),
+				errors.WithContext(errors.Context{"deviceID": deviceID, "i18nCode": code}),
+				errors.WithFault(errors.InverterFault),
+				errors.WithHTTPDetail(errors.ContextHTTPDetail),
+			)
+		}
+	}
+
+	pv := powers[PVPowerI18NCode]
+	feedIn := powers[FeedInPowerI18NCode]
+	purchased := powers[PurchasedPowerI18NCode]
+	charging := powers[BatteryChargingPowerI18NCode]
+	discharging := powers[BatteryDischargingPowerI18NCode]
+	load, ok := powers[LoadPowerI18NCode]
+	if !ok {
+		load = max(pv+discharging+purchased-charging-feedIn, 0)
+	}
+
+	// The PV covers the house first, the surplus charges the battery and the
+	// rest is fed in. Whatever the PV does not cover comes from the battery
+	// and then from the grid.
+	pvToHouse := min(pv, load)
+	pvToBattery := min(pv-pvToHouse, charging)
+	pvToGrid := min(pv-pvToHouse-pvToBattery, feedIn)
+	batteryToHouse := min(discharging, load-pvToHouse)
+	batteryToGrid := max(min(discharging-batteryToHouse, feedIn-pvToGrid), 0)
+	gridToHouse := max(load-pvToHouse-batteryToHouse, 0)
+	gridToBattery := max(charging-pvToBattery, 0)
+
+	f.Metrics = EnergyMetrics{
+		PVPower:          round(pv),
+		HouseConsumption: round(load),
+		BatteryNetPower:  round(charging - discharging),
+		GridNetPower:     round(purchased - feedIn),
+		PVToHouse:        round(pvToHouse),
+		PVToBattery:      round(pvToBattery),
+		PVToGrid:         round(pvToGrid),
+	}
+	if pv > 0 {
+		r := ratio((pv - pvToGrid) / pv)
+		f.Metrics.SelfConsumption = &r
+	}
+	if load > 0 {
+		r := ratio((load - gridToHouse) / load)
+		f.Metrics.Autarky = &r
+	}
+
+	f.Nodes = []EnergyNode{
+		{ID: PVNode, Power: f.Metrics.PVPower},
+		{ID: BatteryNode, Power: f.Metrics.BatteryNetPower, SOC: soc},
+		{ID: GridNode, Power: f.Metrics.GridNetPower},
+		{ID: HouseNode, Power: round(load)},
+	}
+	f.Edges = []EnergyEdge{
+		{From: PVNode, To: HouseNode, Power: round(pvToHouse)},
+		{From: PVNode, To: BatteryNode, Power: round(pvToBattery)},
+		{From: PVNode, To: GridNode, Power: round(pvToGrid)},
+		{From: BatteryNode, To: HouseNode, Power: round(batteryToHouse)},
+		{From: BatteryNode, To: GridNode, Power: round(batteryToGrid)},
+		{From: GridNode, To: HouseNode, Power: round(gridToHouse)},
+		{From: GridNode, To: BatteryNode, Power: round(gridToBattery)},
+	}
+	return f, nil
+}
+
+// parsePower returns the power of the measurement in kW. The inverter reports
+// "--" or nothing instead of 0, e.g. for the PV power at night.
+func parsePower(m RealMeasurement) (float64, error) {
+	if m.Value == "--" || m.Value == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseFloat(m.Value, 64)
+	if err == nil {
+		switch strings.ToLower(m.Unit) {
+		case "kw":
+			return v, nil
+		case "w":
+			return v / 1000, nil
+		}
+	}
+	return 0, errors.New(
+		"invalid power",
+		errors.WithContext(errors.Context{"i18nCode": m.I18NCode, "value": m.Value, "unit": m.Unit}),
+		errors.WithFault(errors.InverterFault),
+		errors.WithHTTPDetail(errors.ContextHTTPDetail),
+		errors.WithHiddenFrames(2),
+	)
+}
+
+// round removes the noise of floating point arithmetic. The inverter reports
+// powers with a resolution of 1 W.
+func round(kw float64) float64 {
+	return math.Round(kw*1000) / 1000
+}
+
+// ratio clamps r to [0, 1] and rounds it to four decimals.
+func ratio(r float64) float64 {
+	return math.Round(min(max(r, 0), 1)*1e4) / 1e4
+}
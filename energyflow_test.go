@@ -0,0 +1,143 @@
+package redgiant
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func powerMeasurements(powers map[string]string) []RealMeasurement {
+	ms := []RealMeasurement{}
+	for code, value := range powers {
+		ms = append(ms, RealMeasurement{I18NCode: code, Value: value, Unit: "kW"})
+	}
+	return ms
+}
+
+func TestNewEnergyFlow(t *testing.T) {
+	ptr := func(f float64) *float64 { return &f }
+
+	tests := []struct {
+		name     string
+		powers   map[string]string
+		expected EnergyMetrics
+	}{
+		{
+			name: "surplus",
+			powers: map[string]string{
+				PVPowerI18NCode:                 "3.1",
+				LoadPowerI18NCode:               "1.2",
+				FeedInPowerI18NCode:             "0.7",
+				PurchasedPowerI18NCode:          "0",
+				BatteryChargingPowerI18NCode:    "1.2",
+				BatteryDischargingPowerI18NCode: "0",
+			},
+			expected: EnergyMetrics{
+				PVPower: 3.1, HouseConsumption: 1.2, BatteryNetPower: 1.2, GridNetPower: -0.7,
+				SelfConsumption: ptr(0.7742), Autarky: ptr(1),
+				PVToHouse: 1.2, PVToBattery: 1.2, PVToGrid: 0.7,
+			},
+		},
+		{
+			name: "night",
+			powers: map[string]string{
+				PVPowerI18NCode:                 "0",
+				LoadPowerI18NCode:               "0.8",
+				FeedInPowerI18NCode:             "0",
+				PurchasedPowerI18NCode:          "0.2",
+				BatteryChargingPowerI18NCode:    "0",
+				BatteryDischargingPowerI18NCode: "0.6",
+			},
+			expected: EnergyMetrics{
+				PVPower: 0, HouseConsumption: 0.8, BatteryNetPower: -0.6, GridNetPower: 0.2,
+				SelfConsumption: nil, Autarky: ptr(0.75),
+			},
+		},
+		{
+			name: "placeholders",
+			powers: map[string]string{
+				PVPowerI18NCode:                 "--",
+				LoadPowerI18NCode:               "0.5",
+				FeedInPowerI18NCode:             "",
+				PurchasedPowerI18NCode:          "0.5",
+				BatteryChargingPowerI18NCode:    "--",
+				BatteryDischargingPowerI18NCode: "--",
+			},
+			expected: EnergyMetrics{
+				PVPower: 0, HouseConsumption: 0.5, BatteryNetPower: 0, GridNetPower: 0.5,
+				SelfConsumption: nil, Autarky: ptr(0),
+			},
+		},
+		{
+			name: "rounding",
+			powers: map[string]string{
+				PVPowerI18NCode:                 "1.2345",
+				LoadPowerI18NCode:               "1.2345",
+				FeedInPowerI18NCode:             "0",
+				PurchasedPowerI18NCode:          "0",
+				BatteryChargingPowerI18NCode:    "0",
+				BatteryDischargingPowerI18NCode: "0",
+			},
+			expected: EnergyMetrics{
+				PVPower: 1.235, HouseConsumption: 1.235, BatteryNetPower: 0, GridNetPower: 0,
+				SelfConsumption: ptr(1), Autarky: ptr(1),
+				PVToHouse: 1.235,
+			},
+		},
+		{
+			name: "without load",
+			powers: map[string]string{
+				PVPowerI18NCode:                 "2",
+				FeedInPowerI18NCode:             "0",
+				PurchasedPowerI18NCode:          "0.5",
+				BatteryChargingPowerI18NCode:    "0",
+				BatteryDischargingPowerI18NCode: "0",
+			},
+			expected: EnergyMetrics{
+				PVPower: 2, HouseConsumption: 2.5, BatteryNetPower: 0, GridNetPower: 0.5,
+				SelfConsumption: ptr(1), Autarky: ptr(0.8),
+				PVToHouse: 2,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f, err := NewEnergyFlow(1, powerMeasurements(test.powers))
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, f.Metrics)
+
+			// the edges into and out of every node have to balance
+			balance := map[EnergyNodeID]float64{}
+			for _, e := range f.Edges {
+				balance[e.From] -= e.Power
+				balance[e.To] += e.Power
+			}
+			assert.InDelta(t, -test.expected.PVPower, balance[PVNode], 1e-9)
+			assert.InDelta(t, test.expected.BatteryNetPower, balance[BatteryNode], 1e-9)
+			assert.InDelta(t, -test.expected.GridNetPower, balance[GridNode], 1e-9)
+			assert.InDelta(t, test.expected.HouseConsumption, balance[HouseNode], 1e-9)
+		})
+	}
+}
+
+func TestNewEnergyFlowErrors(t *testing.T) {
+	_, err := NewEnergyFlow(1, powerMeasurements(map[string]string{PVPowerI18NCode: "1"}))
+	assert.Error(t, err)
+
+	ms := powerMeasurements(map[string]string{
+		PVPowerI18NCode:                 "1",
+		FeedInPowerI18NCode:             "0",
+		PurchasedPowerI18NCode:          "0",
+		BatteryChargingPowerI18NCode:    "0",
+		BatteryDischargingPowerI18NCode: "0",
+	})
+	ms[0].Unit = "kWh"
+	_, err = NewEnergyFlow(1, ms)
+	assert.Error(t, err)
+
+	ms[0].Unit, ms[0].Value = "kW", "n/a"
+	_, err = NewEnergyFlow(1, ms)
+	assert.Error(t, err)
+}
@@ -0,0 +1,29 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/pmeier/redgiant"
+)
+
+// WithAPIKey authenticates requests with an API key of the server.
+func WithAPIKey(key string) redgiant.OptFunc {
+	return redgiant.WithRequestAuth(func(r *http.Request) {
+		r.Header.Set("X-API-Key", key)
+	})
+}
+
+// WithBasicAuth authenticates requests with a user of the server.
+func WithBasicAuth(username string, password string) redgiant.OptFunc {
+	return redgiant.WithRequestAuth(func(r *http.Request) {
+		r.SetBasicAuth(username, password)
+	})
+}
+
+// WithBearerToken authenticates requests with a token, e.g. for a reverse
+// proxy in front of the server.
+func WithBearerToken(token string) redgiant.OptFunc {
+	return redgiant.WithRequestAuth(func(r *http.Request) {
+		r.Header.Set("Authorization", "Bearer "+token)
+	})
+}
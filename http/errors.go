@@ -0,0 +1,102 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Error is an error response of the server.
+type Error struct {
+	StatusCode int
+	// Code identifies the kind of the error, e.g. "unknown-device". It is
+	// empty for generic errors and servers sending the legacy error format.
+	Code    string
+	Message string
+	// Context holds the details the server attached to the error, e.g.
+	// "deviceID" or "language", as decoded by encoding/json.
+	Context map[string]any
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s (%d %s)", e.Message, e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// problemMembers are the members of problem details that are not part of the
+// context.
+var problemMembers = []string{"type", "title", "status", "detail", "instance", "code"}
+
+// newError decodes the error response, which are either RFC 7807 problem
+// details or the legacy {"error": {"message": ..., <context>}} shape. Other
+// bodies, e.g. of a reverse proxy, are used as message verbatim.
+func newError(r *http.Response) *Error {
+	e := &Error{StatusCode: r.StatusCode, Context: map[string]any{}}
+
+	b, _ := io.ReadAll(r.Body)
+	var body map[string]any
+	if err := json.Unmarshal(b, &body); err != nil {
+		e.Message = strings.TrimSpace(string(b))
+	} else if legacy, ok := body["error"].(map[string]any); ok {
+		e.Message, _ = legacy["message"].(string)
+		delete(legacy, "message")
+		e.Context = legacy
+	} else {
+		e.Code, _ = body["code"].(string)
+		if e.Message, _ = body["detail"].(string); e.Message == "" {
+			e.Message, _ = body["title"].(string)
+		}
+		for _, m := range problemMembers {
+			delete(body, m)
+		}
+		e.Context = body
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(r.StatusCode)
+	}
+	return e
+}
+
+// isError matches the code or, for servers sending the legacy error format,
+// the status code and message.
+func isError(err error, code string, statusCode int, msg string) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return false
+	}
+	if e.Code != "" {
+		return e.Code == code
+	}
+	return e.StatusCode == statusCode && e.Message == msg
+}
+
+// IsUnknownDevice reports whether the server does not know the requested
+// device.
+func IsUnknownDevice(err error) bool {
+	return isError(err, "unknown-device", http.StatusUnprocessableEntity, "unknown device")
+}
+
+// IsUnknownLanguage reports whether the server rejected the requested
+// language.
+func IsUnknownLanguage(err error) bool {
+	return isError(err, "unknown-language", http.StatusUnprocessableEntity, "unknown language")
+}
+
+// IsInverterDisconnected reports whether the server is not connected to the
+// inverter. The request can be retried once the connection is re-established.
+func IsInverterDisconnected(err error) bool {
+	return isError(err, "inverter-disconnected", http.StatusServiceUnavailable, "inverter disconnected")
+}
+
+// InverterResultCode returns the result code the inverter answered a request of
+// the server with, if it was not successful.
+func InverterResultCode(err error) (int, bool) {
+	var e *Error
+	if !errors.As(err, &e) {
+		return 0, false
+	}
+	code, ok := e.Context["resultCode"].(float64)
+	return int(code), ok
+}
@@ -1,13 +1,16 @@
 package http
 
 import (
-	"crypto/tls"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"runtime/debug"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/pmeier/redgiant"
@@ -16,42 +19,191 @@ import (
 )
 
 type Redgiant struct {
-	host string
-	c    *http.Client
-	log  zerolog.Logger
+	base            url.URL
+	c               *http.Client
+	log             zerolog.Logger
+	userAgent       string
+	auth            func(*http.Request)
+	retries         uint
+	minRetryBackoff time.Duration
+	maxRetryBackoff time.Duration
 }
 
+var _ redgiant.Client = (*Redgiant)(nil)
+
 func NewRedgiant(host string, port uint, opts ...redgiant.OptFunc) *Redgiant {
+	return newRedgiant(url.URL{Scheme: "http", Host: net.JoinHostPort(host, strconv.Itoa(int(port)))}, opts...)
+}
+
+// NewRedgiantFromURL creates a client for the server at baseURL, e.g.
+// https://redgiant.local:8000 or https://example.com/redgiant behind a reverse
+// proxy. unix:///run/redgiant.sock connects through a unix socket, see
+// redgiant.WithUnixSocket for sockets with a path prefix. Server certificates
+// are verified against the system roots. Use redgiant.WithTLSConfig to trust
+// custom CAs, e.g. of a self-signed certificate, or
+// redgiant.WithInsecureSkipVerify to disable the verification.
+func NewRedgiantFromURL(baseURL string, opts ...redgiant.OptFunc) (*Redgiant, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "unix":
+		opts = append(opts, redgiant.WithUnixSocket(u.Path))
+		u = &url.URL{Scheme: "http", Host: "localhost"}
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+	return newRedgiant(*u, opts...), nil
+}
+
+func newRedgiant(base url.URL, opts ...redgiant.OptFunc) *Redgiant {
 	o := redgiant.ResolveOptions(append([]redgiant.OptFunc{
 		redgiant.WithLogger(log.Logger),
 		redgiant.WithHTTPClient(&http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-			},
-			Timeout: time.Second * 60,
+			Transport: &http.Transport{},
+			Timeout:   time.Second * 60,
 		}),
+		redgiant.WithUserAgent(defaultUserAgent()),
 	}, opts...)...)
-	return &Redgiant{host: fmt.Sprintf("%s:%d", host, port), c: o.HTTPClient, log: o.Logger}
+
+	c := o.ApplyTLSConfig()
+	if o.UnixSocket != "" {
+		c = withUnixSocket(c, o.UnixSocket)
+	}
+
+	return &Redgiant{
+		base:            base,
+		c:               c,
+		log:             o.Logger,
+		userAgent:       o.UserAgent,
+		auth:            o.RequestAuth,
+		retries:         o.Retries,
+		minRetryBackoff: o.MinRetryBackoff,
+		maxRetryBackoff: o.MaxRetryBackoff,
+	}
 }
 
+// withUnixSocket returns a copy of the client that dials the socket regardless
+// of the host.
+func withUnixSocket(c *http.Client, path string) *http.Client {
+	uc := *c
+	var t *http.Transport
+	if ht, ok := c.Transport.(*http.Transport); ok {
+		t = ht.Clone()
+	} else {
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	t.Proxy = nil
+	t.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, "unix", path)
+	}
+	uc.Transport = t
+	return &uc
+}
+
+// defaultUserAgent identifies the client by the version of the module it was
+// built from.
+func defaultUserAgent() string {
+	version := "(devel)"
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if bi.Main.Path == modulePath {
+			version = bi.Main.Version
+		}
+		for _, m := range bi.Deps {
+			if m.Path == modulePath {
+				version = m.Version
+			}
+		}
+	}
+	return fmt.Sprintf("redgiant-go/%s", version)
+}
+
+const modulePath = "github.com/pmeier/redgiant"
+
+func (rg *Redgiant) url(path string) url.URL {
+	u := rg.base
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	return u
+}
+
+// assertResponseSuccessful returns an *Error for non-2xx responses.
 func assertResponseSuccessful(r *http.Response) error {
 	if r.StatusCode >= 200 && r.StatusCode < 300 {
 		return nil
 	}
+	return newError(r)
+}
 
-	var msg string
-	if c, _ := io.ReadAll(r.Body); len(c) == 0 {
-		msg = r.Status
-	} else {
-		msg = string(c)
+// retryAfter parses the Retry-After header, which is either a number of
+// seconds or a date.
+func retryAfter(r *http.Response) (time.Duration, bool) {
+	h := r.Header.Get("Retry-After")
+	if h == "" {
+		return 0, false
+	}
+	if s, err := strconv.Atoi(h); err == nil {
+		return time.Duration(max(s, 0)) * time.Second, true
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
+
+func isRetryable(statusCode int) bool {
+	switch statusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// get requests the URL. Since GET requests are idempotent, failed requests
+// and responses asking to come back later are retried.
+func (rg *Redgiant) get(u url.URL) (*http.Response, error) {
+	backoff := rg.minRetryBackoff
+	for try := uint(0); ; try++ {
+		req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("User-Agent", rg.userAgent)
+		if rg.auth != nil {
+			rg.auth(req)
+		}
+
+		r, err := rg.c.Do(req)
+		if try == rg.retries || (err == nil && !isRetryable(r.StatusCode)) {
+			return r, err
+		}
+
+		wait := backoff
+		if err == nil {
+			if d, ok := retryAfter(r); ok {
+				if d > rg.maxRetryBackoff {
+					return r, nil
+				}
+				wait = d
+			}
+			// drain the body so the connection can be reused
+			io.Copy(io.Discard, r.Body)
+			r.Body.Close()
+		}
+
+		rg.log.Debug().Err(err).Uint("try", try).Dur("wait", wait).Msg("retrying request")
+		time.Sleep(wait)
+		backoff = min(2*backoff, rg.maxRetryBackoff)
 	}
-	return errors.New(msg)
 }
 
 func (rg *Redgiant) Health() error {
 	rg.log.Trace().Msg("Redgiant.Health()")
 
-	r, err := rg.c.Get((&url.URL{Scheme: "http", Host: rg.host, Path: "/health"}).String())
+	u := rg.url("/health")
+	r, err := rg.get(u)
 	if err != nil {
 		return err
 	}
@@ -63,11 +215,11 @@ func (rg *Redgiant) Health() error {
 func (rg *Redgiant) getAPI(endpoint string, query url.Values, v any) error {
 	rg.log.Trace().Str("endpoint", endpoint).Func(func(e *zerolog.Event) { e.Str("query", query.Encode()) }).Msg("Redgiant.getAPI()")
 
-	u := url.URL{Scheme: "http", Host: rg.host, Path: fmt.Sprintf("/api%s", endpoint)}
+	u := rg.url(fmt.Sprintf("/api%s", endpoint))
 	u.RawQuery = query.Encode()
 
 	rg.log.Debug().Func(func(e *zerolog.Event) { e.Str("url", u.String()) }).Msg("GET")
-	r, err := rg.c.Get(u.String())
+	r, err := rg.get(u)
 	if err != nil {
 		return err
 	}
@@ -104,7 +256,9 @@ func (rg *Redgiant) Devices() ([]redgiant.Device, error) {
 func dataEndpointQuery(dataType string, deviceID int, lang redgiant.Language, services []string) (string, url.Values) {
 	e := fmt.Sprintf("/data/%d/%s", deviceID, dataType)
 	q := url.Values{}
-	q.Add("lang", lang.String())
+	if lang != redgiant.NoLanguage {
+		q.Add("lang", lang.String())
+	}
 	for _, s := range services {
 		q.Add("service", s)
 	}
@@ -126,3 +280,27 @@ func (rg *Redgiant) DirectData(deviceID int, lang redgiant.Language, services ..
 	var dms []redgiant.DirectMeasurement
 	return dms, rg.getAPI(endpoint, q, &dms)
 }
+
+func (rg *Redgiant) Snapshot(lang redgiant.Language) (redgiant.Snapshot, error) {
+	rg.log.Trace().Stringer("lang", lang).Msg("Redgiant.Snapshot()")
+
+	q := url.Values{}
+	if lang != redgiant.NoLanguage {
+		q.Add("lang", lang.String())
+	}
+	var s redgiant.Snapshot
+	return s, rg.getAPI("/snapshot", q, &s)
+}
+
+// EnergyFlow returns the energy flow of a hybrid inverter. The device ID 0
+// selects the first one.
+func (rg *Redgiant) EnergyFlow(deviceID int) (redgiant.EnergyFlow, error) {
+	rg.log.Trace().Int("deviceID", deviceID).Msg("Redgiant.EnergyFlow()")
+
+	q := url.Values{}
+	if deviceID != 0 {
+		q.Add("device", strconv.Itoa(deviceID))
+	}
+	var f redgiant.EnergyFlow
+	return f, rg.getAPI("/energyflow", q, &f)
+}
@@ -0,0 +1,193 @@
+package http
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedgiantRequest(t *testing.T) {
+	var req *http.Request
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req = r
+		w.Write([]byte(`{"serialNumber": "A2340000000"}`))
+	}))
+	l, err := net.Listen("unix", filepath.Join(t.TempDir(), "redgiant.sock"))
+	require.NoError(t, err)
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	tcpSrv := httptest.NewServer(srv.Config.Handler)
+	t.Cleanup(tcpSrv.Close)
+
+	tests := []struct {
+		name         string
+		baseURL      string
+		opts         []redgiant.OptFunc
+		expectedPath string
+		check        func(t *testing.T, r *http.Request)
+	}{
+		{
+			name:         "prefix",
+			baseURL:      tcpSrv.URL + "/redgiant/",
+			expectedPath: "/redgiant/api/about",
+		},
+		{
+			name:         "unix",
+			baseURL:      "unix://" + l.Addr().String(),
+			expectedPath: "/api/about",
+		},
+		{
+			name:         "unix-prefix",
+			baseURL:      "http://localhost/redgiant",
+			opts:         []redgiant.OptFunc{redgiant.WithUnixSocket(l.Addr().String())},
+			expectedPath: "/redgiant/api/about",
+		},
+		{
+			name:         "api-key",
+			baseURL:      tcpSrv.URL,
+			opts:         []redgiant.OptFunc{WithAPIKey("secret")},
+			expectedPath: "/api/about",
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "secret", r.Header.Get("X-API-Key"))
+			},
+		},
+		{
+			name:         "basic",
+			baseURL:      tcpSrv.URL,
+			opts:         []redgiant.OptFunc{WithBasicAuth("user", "pass")},
+			expectedPath: "/api/about",
+			check: func(t *testing.T, r *http.Request) {
+				username, password, ok := r.BasicAuth()
+				assert.True(t, ok)
+				assert.Equal(t, "user", username)
+				assert.Equal(t, "pass", password)
+			},
+		},
+		{
+			name:         "bearer",
+			baseURL:      tcpSrv.URL,
+			opts:         []redgiant.OptFunc{WithBearerToken("token")},
+			expectedPath: "/api/about",
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+			},
+		},
+		{
+			name:         "user-agent",
+			baseURL:      tcpSrv.URL,
+			opts:         []redgiant.OptFunc{redgiant.WithUserAgent("test/1.0")},
+			expectedPath: "/api/about",
+			check: func(t *testing.T, r *http.Request) {
+				assert.Equal(t, "test/1.0", r.UserAgent())
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rg, err := NewRedgiantFromURL(test.baseURL, test.opts...)
+			require.NoError(t, err)
+
+			a, err := rg.About()
+			require.NoError(t, err)
+			assert.Equal(t, "A2340000000", a.SerialNumber)
+			assert.Equal(t, test.expectedPath, req.URL.Path)
+			if test.check != nil {
+				test.check(t, req)
+			} else {
+				assert.True(t, strings.HasPrefix(req.UserAgent(), "redgiant-go/"))
+			}
+		})
+	}
+}
+
+func TestRedgiantRetries(t *testing.T) {
+	tests := []struct {
+		name          string
+		retryAfter    string
+		failures      int32
+		retries       uint
+		expectedCalls int32
+		shouldError   bool
+	}{
+		{name: "success", failures: 2, retries: 2, expectedCalls: 3},
+		{name: "exhausted", failures: 3, retries: 2, expectedCalls: 3, shouldError: true},
+		{name: "disabled", failures: 1, retries: 0, expectedCalls: 1, shouldError: true},
+		{name: "retry-after", retryAfter: "0", failures: 1, retries: 1, expectedCalls: 2},
+		{name: "retry-after-too-long", retryAfter: "3600", failures: 1, retries: 1, expectedCalls: 1, shouldError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if calls.Add(1) <= test.failures {
+					if test.retryAfter != "" {
+						w.Header().Set("Retry-After", test.retryAfter)
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					w.Write([]byte(`{"error": {"message": "inverter disconnected"}}`))
+					return
+				}
+				w.Write([]byte(`{}`))
+			}))
+			t.Cleanup(srv.Close)
+
+			rg, err := NewRedgiantFromURL(srv.URL, redgiant.WithRetries(test.retries, time.Millisecond, 10*time.Millisecond))
+			require.NoError(t, err)
+
+			_, err = rg.State()
+			if test.shouldError {
+				assert.True(t, IsInverterDisconnected(err))
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, test.expectedCalls, calls.Load())
+		})
+	}
+}
+
+func TestRedgiantTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"serialNumber": "A2340000000"}`))
+	}))
+	t.Cleanup(srv.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	tests := []struct {
+		name        string
+		opts        []redgiant.OptFunc
+		shouldError bool
+	}{
+		{name: "verified by default", shouldError: true},
+		{name: "custom CA", opts: []redgiant.OptFunc{redgiant.WithTLSConfig(&tls.Config{RootCAs: pool})}},
+		{name: "insecure", opts: []redgiant.OptFunc{redgiant.WithInsecureSkipVerify(true)}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rg, err := NewRedgiantFromURL(srv.URL, test.opts...)
+			require.NoError(t, err)
+
+			_, err = rg.About()
+			if test.shouldError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
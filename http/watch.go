@@ -0,0 +1,290 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmeier/redgiant"
+)
+
+const (
+	defaultWatchMinBackoff = time.Second
+	defaultWatchMaxBackoff = 30 * time.Second
+	// watchTimeout is the time without any data after which a stream is
+	// considered dead. The server sends keep-alive comments every 15 seconds.
+	watchTimeout = 45 * time.Second
+	// maxEventSize limits the size of a single line of the stream.
+	maxEventSize = 4 << 20
+)
+
+// WatchFilter selects the data Watch receives. Empty fields select everything.
+type WatchFilter struct {
+	DeviceIDs []int
+	I18NCodes []string
+}
+
+// WatchState is the state of the stream of a Watcher.
+type WatchState struct {
+	Connected bool
+	// InverterAvailable reports whether the server could read the inverter
+	// during its last pass.
+	InverterAvailable bool
+	// Err is the error the stream was lost with.
+	Err   error
+	Since time.Time
+}
+
+// Watcher receives the data the server samples from the inverter.
+type Watcher struct {
+	// C receives the snapshots. It is closed once the context is done or the
+	// server refused the stream.
+	C <-chan redgiant.Snapshot
+	// States receives the state whenever it changes. Only the latest state is
+	// kept if it is not received in time.
+	States <-chan WatchState
+
+	rg          *Redgiant
+	c           *http.Client
+	u           url.URL
+	snapshots   chan redgiant.Snapshot
+	states      chan WatchState
+	mu          sync.Mutex
+	state       WatchState
+	lastEventID string
+}
+
+// Watch subscribes to the stream of the server, which requires the stream
+// scope. If the stream is lost, Watch reconnects with the backoff of
+// redgiant.WithRetries and resumes where it left off. The error is non-nil if
+// the first connection fails.
+func (rg *Redgiant) Watch(ctx context.Context, filter WatchFilter) (*Watcher, error) {
+	rg.log.Trace().Ints("deviceIDs", filter.DeviceIDs).Strs("i18nCodes", filter.I18NCodes).Msg("Redgiant.Watch()")
+
+	u := rg.url("/api/stream")
+	q := url.Values{}
+	for _, id := range filter.DeviceIDs {
+		q.Add("device", strconv.Itoa(id))
+	}
+	for _, code := range filter.I18NCodes {
+		q.Add("metric", code)
+	}
+	u.RawQuery = q.Encode()
+
+	// the timeout of the client would end the stream
+	c := *rg.c
+	c.Timeout = 0
+
+	w := &Watcher{
+		rg:        rg,
+		c:         &c,
+		u:         u,
+		snapshots: make(chan redgiant.Snapshot),
+		states:    make(chan WatchState, 1),
+	}
+	w.C = w.snapshots
+	w.States = w.states
+
+	r, cancel, err := w.connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	go w.run(ctx, r, cancel)
+	return w, nil
+}
+
+// State returns the current state of the stream.
+func (w *Watcher) State() WatchState {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.state
+}
+
+func (w *Watcher) setState(fn func(s *WatchState)) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	s := w.state
+	fn(&s)
+	if s.Connected != w.state.Connected {
+		s.Since = time.Now()
+	}
+	if s == w.state {
+		return
+	}
+	w.state = s
+
+	// replace a state that was not received yet
+	select {
+	case <-w.states:
+	default:
+	}
+	w.states <- s
+}
+
+// connect opens the stream. The returned function cancels the request.
+func (w *Watcher) connect(ctx context.Context) (*http.Response, context.CancelFunc, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.u.String(), nil)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("User-Agent", w.rg.userAgent)
+	if w.lastEventID != "" {
+		req.Header.Set("Last-Event-ID", w.lastEventID)
+	}
+	if w.rg.auth != nil {
+		w.rg.auth(req)
+	}
+
+	r, err := w.c.Do(req)
+	if err != nil {
+		cancel()
+		return nil, nil, err
+	}
+	if err := assertResponseSuccessful(r); err != nil {
+		r.Body.Close()
+		cancel()
+		return nil, nil, err
+	}
+
+	w.setState(func(s *WatchState) {
+		s.Connected = true
+		s.Err = nil
+	})
+	return r, cancel, nil
+}
+
+// isPermanent reports whether reconnecting is futile, e.g. since the
+// credentials are invalid.
+func isPermanent(err error) bool {
+	var e *Error
+	return errors.As(err, &e) && e.StatusCode >= 400 && e.StatusCode < 500 && e.StatusCode != http.StatusTooManyRequests
+}
+
+func (w *Watcher) run(ctx context.Context, r *http.Response, cancel context.CancelFunc) {
+	defer close(w.snapshots)
+
+	minBackoff, maxBackoff := w.rg.minRetryBackoff, w.rg.maxRetryBackoff
+	if minBackoff == 0 {
+		minBackoff = defaultWatchMinBackoff
+	}
+	if maxBackoff == 0 {
+		maxBackoff = defaultWatchMaxBackoff
+	}
+	backoff := minBackoff
+
+	for {
+		err := w.read(ctx, r.Body, cancel)
+		r.Body.Close()
+		cancel()
+		if ctx.Err() != nil {
+			w.setState(func(s *WatchState) { s.Connected = false })
+			return
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		w.rg.log.Debug().Err(err).Msg("stream lost")
+		w.setState(func(s *WatchState) {
+			s.Connected = false
+			s.Err = err
+		})
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			r, cancel, err = w.connect(ctx)
+			if err == nil {
+				backoff = minBackoff
+				break
+			}
+			w.rg.log.Debug().Err(err).Dur("retry", backoff).Msg("unable to resume stream")
+			w.setState(func(s *WatchState) { s.Err = err })
+			if isPermanent(err) {
+				return
+			}
+			backoff = min(2*backoff, maxBackoff)
+		}
+	}
+}
+
+// read dispatches the server-sent events of the stream until it ends.
+func (w *Watcher) read(ctx context.Context, body io.Reader, cancel context.CancelFunc) error {
+	// a dead connection is only noticed by the lack of keep-alives
+	watchdog := time.AfterFunc(watchTimeout, cancel)
+	defer watchdog.Stop()
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, maxEventSize)
+
+	var id, event string
+	var data []string
+	for scanner.Scan() {
+		watchdog.Reset(watchTimeout)
+
+		line := scanner.Text()
+		if line == "" {
+			if len(data) > 0 {
+				if err := w.dispatch(ctx, event, strings.Join(data, "\n")); err != nil {
+					return err
+				}
+			}
+			if id != "" {
+				w.lastEventID = id
+			}
+			id, event, data = "", "", nil
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "id":
+			id = value
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	return scanner.Err()
+}
+
+func (w *Watcher) dispatch(ctx context.Context, event string, data string) error {
+	switch event {
+	case "sample":
+		var s redgiant.Snapshot
+		if err := json.Unmarshal([]byte(data), &s); err != nil {
+			return err
+		}
+		select {
+		case w.snapshots <- s:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	case "availability":
+		var a struct {
+			Available bool `json:"available"`
+		}
+		if err := json.Unmarshal([]byte(data), &a); err != nil {
+			return err
+		}
+		w.setState(func(s *WatchState) { s.InverterAvailable = a.Available })
+	}
+	return nil
+}
@@ -1,66 +1,139 @@
 package redgiant
 
 import (
-	"bufio"
+	"bytes"
+	"context"
 	"crypto/tls"
+	"embed"
 	"fmt"
+	"io"
+	"maps"
 	"net/http"
+	"os"
+	"path"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/pmeier/redgiant/internal/errors"
-	"github.com/pmeier/redgiant/internal/utils"
+	"github.com/pmeier/redgiant/internal/properties"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
 )
 
-type Language uint8
+// Language identifies a catalog of translations in the notation of the
+// inverter, e.g. "de_DE". Use ParseLanguage to create one from a BCP 47 tag.
+type Language string
 
 const (
-	NoLanguage Language = iota
-	ChineseLanguage
-	EnglishLanguage
-	GermanLanguage
-	DutchLanguage
-	PolishLanguage
+	NoLanguage      Language = ""
+	ChineseLanguage Language = "ch_CN"
+	EnglishLanguage Language = "en_US"
+	GermanLanguage  Language = "de_DE"
+	DutchLanguage   Language = "nl_NL"
+	PolishLanguage  Language = "pl_PL"
 )
 
+// builtinLanguages are shipped by every inverter and have an embedded fallback
+// bundle.
+var builtinLanguages = []Language{
+	EnglishLanguage,
+	ChineseLanguage,
+	GermanLanguage,
+	DutchLanguage,
+	PolishLanguage,
+}
+
+// candidateLanguages are probed to discover which additional languages an
+// inverter ships.
+var candidateLanguages = append(slices.Clone(builtinLanguages),
+	"fr_FR", "it_IT", "es_ES", "pt_PT", "pt_BR", "cs_CZ", "hu_HU", "ro_RO",
+	"sv_SE", "el_GR", "tr_TR", "uk_UA", "ru_RU", "ja_JP", "ko_KR", "vi_VN",
+	"th_TH",
+)
+
+var simplifiedChinese = language.MustParse("zh-CN")
+
 func (l Language) String() string {
-	switch l {
-	case NoLanguage:
-		return ""
-	case ChineseLanguage:
-		return "ch_CN"
-	case EnglishLanguage:
-		return "en_US"
-	case GermanLanguage:
-		return "de_DE"
-	case DutchLanguage:
-		return "nl_NL"
-	case PolishLanguage:
-		return "pl_PL"
+	return string(l)
+}
+
+// Tag returns the BCP 47 tag of the language.
+func (l Language) Tag() language.Tag {
+	// the inverter uses "ch" rather than the ISO 639 code "zh"
+	if l == ChineseLanguage {
+		return simplifiedChinese
+	}
+	t, err := language.Parse(strings.ReplaceAll(string(l), "_", "-"))
+	if err != nil {
+		return language.Und
 	}
-	return strconv.Itoa(int(l))
+	return t
 }
 
+// LanguageFromTag converts a BCP 47 tag into the notation of the inverter. A
+// missing region is inferred, e.g. "de" becomes "de_DE".
+func LanguageFromTag(t language.Tag) Language {
+	if t == language.Und {
+		return NoLanguage
+	}
+
+	base, _ := t.Base()
+	region, _ := t.Region()
+	if lang := Language(base.String() + "_" + region.String()); lang != "zh_CN" {
+		return lang
+	}
+	return ChineseLanguage
+}
+
+// ParseLanguage accepts BCP 47 tags as well as the notation of the inverter,
+// e.g. "de-AT", "de" or "de_DE".
 func ParseLanguage(langStr string) (Language, error) {
-	for _, lang := range []Language{
-		NoLanguage,
-		ChineseLanguage,
-		EnglishLanguage,
-		GermanLanguage,
-		DutchLanguage,
-		PolishLanguage,
-	} {
-		if strings.EqualFold(langStr, lang.String()) {
-			return lang, nil
-		}
+	if langStr == "" {
+		return NoLanguage, nil
+	}
+	if strings.EqualFold(strings.ReplaceAll(langStr, "-", "_"), ChineseLanguage.String()) {
+		return ChineseLanguage, nil
+	}
+
+	t, err := language.Parse(strings.ReplaceAll(langStr, "_", "-"))
+	if err != nil || t == language.Und {
+		return NoLanguage, errors.New(
+			"unknown language",
+			errors.WithCode(errors.UnknownLanguageCode),
+			errors.WithContext(errors.Context{"language": langStr}),
+			errors.WithHTTPCode(http.StatusUnprocessableEntity),
+			errors.WithFault(errors.ClientFault),
+			errors.WithHTTPDetail(errors.ContextHTTPDetail),
+		)
+	}
+	return LanguageFromTag(t), nil
+}
+
+// MatchLanguage returns the supported language that fits the preferred ones
+// best, e.g. de_DE for de-AT. Without any match, EnglishLanguage is returned
+// if supported and the first supported language otherwise.
+func MatchLanguage(supported []Language, preferred ...language.Tag) Language {
+	if len(supported) == 0 {
+		return EnglishLanguage
+	}
+
+	// the matcher falls back to the first language
+	if i := slices.Index(supported, EnglishLanguage); i > 0 {
+		supported = slices.Concat([]Language{EnglishLanguage}, supported[:i], supported[i+1:])
+	}
+	tags := make([]language.Tag, len(supported))
+	for i, lang := range supported {
+		tags[i] = lang.Tag()
 	}
-	return NoLanguage, errors.New(
-		"unknown language",
-		errors.WithContext(errors.Context{"language": langStr}),
-		errors.WithHTTPCode(http.StatusUnprocessableEntity),
-		errors.WithHTTPDetail(errors.ContextHTTPDetail),
-	)
+
+	_, i, _ := language.NewMatcher(tags).Match(preferred...)
+	return supported[i]
 }
 
 func (l *Language) UnmarshalParam(param string) error {
@@ -76,79 +149,533 @@ type Localizer interface {
 	Localize(i18nCode string, lang Language) (string, error)
 }
 
+// Preloader is implemented by Localizers that can load languages ahead of
+// their first use.
+type Preloader interface {
+	Preload(langs ...Language) error
+}
+
+// LanguageLister is implemented by Localizers that know which languages they
+// have translations for.
+type LanguageLister interface {
+	Languages() []Language
+}
+
+// fallbackRetryInterval is the time after which a language served from a
+// fallback is downloaded from the inverter again.
+const fallbackRetryInterval = 5 * time.Minute
+
+// fallbackFS holds bundles for the builtin languages that are used if the
+// inverter is unreachable and no cached download is available. They are
+// hand-written and only cover a few common codes, so names of other codes
+// cannot be resolved until the inverter is reachable again. Only the cache
+// provides complete translations while it is unreachable.
+//
+//go:embed i18n/*.properties
+var fallbackFS embed.FS
+
+type codeMap struct {
+	codes map[string]string
+	// fallback is set if the codes were not downloaded for the current
+	// firmware, in which case a download is attempted again after retryAt.
+	fallback bool
+	retryAt  time.Time
+}
+
 type SungrowLocalizer struct {
-	host string
-	lm   map[Language]map[string]string
-	re   *regexp.Regexp
+	host     string
+	c        *http.Client
+	log      zerolog.Logger
+	cacheDir string
+	version  func() (string, error)
+	lm       map[Language]codeMap
+	mu       sync.Mutex
+	loads    map[Language]*codeMapLoad
+	// languages are the languages discovered on the inverter. They are
+	// discovered again after languagesRetryAt if the discovery failed.
+	languagesMu      sync.Mutex
+	languages        []Language
+	languagesRetryAt time.Time
 }
 
-func NewSungrowLocalizer(host string) *SungrowLocalizer {
+func NewSungrowLocalizer(host string, opts ...OptFunc) *SungrowLocalizer {
+	o := ResolveOptions(append([]OptFunc{
+		WithLogger(log.Logger),
+		WithHTTPClient(&http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+			},
+			Timeout: time.Second * 60,
+		}),
+	}, opts...)...)
 	return &SungrowLocalizer{
-		host: host,
-		lm:   map[Language]map[string]string{},
-		re:   regexp.MustCompile(`{\d+}`),
+		host:     host,
+		c:        o.ApplyTLSConfig(),
+		log:      o.Logger,
+		cacheDir: o.CacheDir,
+		version:  o.FirmwareVersion,
+		lm:       map[Language]codeMap{},
+		loads:    map[Language]*codeMapLoad{},
+	}
+}
+
+const (
+	// discoveryTimeout bounds the time spent probing for the languages
+	// shipped by the inverter.
+	discoveryTimeout = 10 * time.Second
+	// discoveryWorkers limits the concurrent probes, since the inverter
+	// serves requests slowly.
+	discoveryWorkers = 3
+)
+
+// Languages returns the languages the inverter ships translations for. They
+// are cached per firmware version. While the inverter is unreachable, the
+// languages available offline are returned.
+func (l *SungrowLocalizer) Languages() []Language {
+	// concurrent callers wait for a discovery in progress
+	l.languagesMu.Lock()
+	defer l.languagesMu.Unlock()
+
+	if l.languages != nil && (l.languagesRetryAt.IsZero() || time.Now().Before(l.languagesRetryAt)) {
+		return slices.Clone(l.languages)
+	}
+
+	version := l.firmwareVersion()
+	if version != "" {
+		if langs, err := l.readLanguagesCache(version); err == nil {
+			l.languages, l.languagesRetryAt = langs, time.Time{}
+			return slices.Clone(langs)
+		}
+	}
+
+	langs, err := l.discoverLanguages()
+	if err != nil {
+		l.log.Warn().Err(err).Msg("unable to discover languages")
+		for _, lang := range l.offlineLanguages() {
+			if !slices.Contains(langs, lang) {
+				langs = append(langs, lang)
+			}
+		}
+		l.languages, l.languagesRetryAt = langs, time.Now().Add(fallbackRetryInterval)
+		return slices.Clone(langs)
+	}
+	if err := l.writeLanguagesCache(version, langs); err != nil {
+		l.log.Warn().Err(err).Msg("unable to cache languages")
+	}
+	l.languages, l.languagesRetryAt = langs, time.Time{}
+	return slices.Clone(langs)
+}
+
+// discoverLanguages probes the inverter for the candidate languages. The
+// languages found are returned even if some probes failed.
+func (l *SungrowLocalizer) discoverLanguages() ([]Language, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+
+	shipped := make([]bool, len(candidateLanguages))
+	errs := make([]error, len(candidateLanguages))
+	indices := make(chan int)
+	var wg sync.WaitGroup
+	for range discoveryWorkers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indices {
+				shipped[i], errs[i] = l.probe(ctx, candidateLanguages[i])
+			}
+		}()
+	}
+	for i := range candidateLanguages {
+		indices <- i
+	}
+	close(indices)
+	wg.Wait()
+
+	langs := []Language{}
+	for i, lang := range candidateLanguages {
+		if shipped[i] {
+			langs = append(langs, lang)
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return langs, err
+		}
+	}
+	return langs, nil
+}
+
+// probe reports whether the inverter ships the language. Only the first byte
+// of the translations is requested, since servers ignoring the range send
+// all of them.
+func (l *SungrowLocalizer) probe(ctx context.Context, lang Language) (bool, error) {
+	u := fmt.Sprintf("https://%s/i18n/%s.properties", l.host, lang)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return false, errors.Wrap(err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	r, err := l.c.Do(req)
+	if err != nil {
+		return false, errors.Wrap(err, errors.WithContext(errors.Context{"url": u}))
 	}
+	r.Body.Close()
+
+	switch r.StatusCode {
+	case http.StatusOK, http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		// the latter is sent for empty translations
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+	return false, errors.New("unexpected status", errors.WithContext(errors.Context{"url": u, "status": r.Status}))
+}
+
+// offlineLanguages are the languages available from the cache or the embedded
+// fallback bundles.
+func (l *SungrowLocalizer) offlineLanguages() []Language {
+	langs := slices.Clone(builtinLanguages)
+	if l.cacheDir == "" {
+		return langs
+	}
+
+	files, _ := filepath.Glob(filepath.Join(l.cacheDir, "*", "*.properties"))
+	for _, file := range files {
+		lang := Language(strings.TrimSuffix(filepath.Base(file), ".properties"))
+		if !slices.Contains(langs, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// match resolves lang to a language the inverter ships, e.g. de_AT to de_DE.
+func (l *SungrowLocalizer) match(lang Language) Language {
+	if slices.Contains(builtinLanguages, lang) {
+		return lang
+	}
+	return MatchLanguage(l.Languages(), lang.Tag())
+}
+
+// codeMapLoad is a load of a language in progress, which concurrent callers
+// wait for rather than loading the language themselves.
+type codeMapLoad struct {
+	done  chan struct{}
+	codes map[string]string
+	err   error
 }
 
 func (l *SungrowLocalizer) getCodeMap(lang Language) (map[string]string, error) {
+	l.mu.Lock()
 	cm, ok := l.lm[lang]
-	if ok {
-		return cm, nil
+	if ok && (!cm.fallback || time.Now().Before(cm.retryAt)) {
+		l.mu.Unlock()
+		return cm.codes, nil
+	}
+	load, loading := l.loads[lang]
+	if loading && ok {
+		// keep serving the fallback while it is being replaced
+		l.mu.Unlock()
+		return cm.codes, nil
+	} else if !loading {
+		load = &codeMapLoad{done: make(chan struct{})}
+		l.loads[lang] = load
+	}
+	l.mu.Unlock()
+
+	if loading {
+		<-load.done
+		return load.codes, load.err
+	}
+
+	cm, load.err = l.loadCodeMap(lang)
+	load.codes = cm.codes
+
+	l.mu.Lock()
+	if load.err == nil {
+		l.lm[lang] = cm
+	}
+	delete(l.loads, lang)
+	l.mu.Unlock()
+	close(load.done)
+
+	return load.codes, load.err
+}
+
+// firmwareVersion returns the version the cache is keyed by. It is empty if
+// the cache is disabled or the version is unknown.
+func (l *SungrowLocalizer) firmwareVersion() string {
+	if l.version == nil || l.cacheDir == "" {
+		return ""
+	}
+	v, err := l.version()
+	if err != nil {
+		l.log.Debug().Err(err).Msg("unable to determine firmware version")
+	}
+	return v
+}
+
+func (l *SungrowLocalizer) loadCodeMap(lang Language) (codeMap, error) {
+	log := l.log.With().Stringer("lang", lang).Logger()
+
+	version := l.firmwareVersion()
+	if version != "" {
+		if codes, err := l.readCache(l.cachePath(version, lang)); err == nil {
+			return codeMap{codes: codes}, nil
+		}
+	}
+
+	codes, err := l.download(version, lang)
+	if err == nil {
+		return codeMap{codes: codes}, nil
 	}
 
-	c := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+	var ferr error
+	codes, ferr = l.readLatestCache(lang)
+	if ferr != nil {
+		codes, ferr = l.readFallback(lang)
+	}
+	if ferr != nil {
+		return codeMap{}, err
+	}
+	log.Warn().Err(err).Msg("unable to download translations, using fallback")
+	return codeMap{codes: codes, fallback: true, retryAt: time.Now().Add(fallbackRetryInterval)}, nil
+}
+
+// Preload loads the languages ahead of their first use. Languages that cannot
+// be loaded are reported, but do not prevent the others from loading.
+func (l *SungrowLocalizer) Preload(langs ...Language) error {
+	errs := make([]error, len(langs))
+	var wg sync.WaitGroup
+	for i, lang := range langs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = l.getCodeMap(l.match(lang))
+		}()
+	}
+	wg.Wait()
+
+	var failed []string
+	for i, err := range errs {
+		if err != nil {
+			l.log.Warn().Err(err).Stringer("lang", langs[i]).Msg("unable to preload language")
+			failed = append(failed, langs[i].String())
+		}
+	}
+	if len(failed) > 0 {
+		return errors.New("unable to preload languages", errors.WithContext(errors.Context{"languages": failed}))
+	}
+	return nil
+}
+
+func (l *SungrowLocalizer) download(version string, lang Language) (map[string]string, error) {
 	u := fmt.Sprintf("https://%s/i18n/%s.properties", l.host, lang)
-	r, err := c.Get(u)
+	r, err := l.c.Get(u)
 	if err != nil {
 		return nil, errors.Wrap(err, errors.WithContext(errors.Context{"url": u}))
 	}
 	defer r.Body.Close()
+	if r.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected status", errors.WithContext(errors.Context{"url": u, "status": r.Status}))
+	}
 
-	s := bufio.NewScanner(r.Body)
-	cm = map[string]string{}
-	for s.Scan() {
-		l := s.Text()
-		parts := strings.SplitN(l, "=", 2)
-		if len(parts) != 2 {
-			return nil, errors.New("unknown line format", errors.WithContext(errors.Context{"line": l}))
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, errors.Wrap(err, errors.WithContext(errors.Context{"url": u}))
+	}
+	codes, err := parseCodeMap(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.writeCache(version, lang, b); err != nil {
+		l.log.Warn().Err(err).Stringer("lang", lang).Msg("unable to cache translations")
+	}
+	return codes, nil
+}
+
+var unsafePathCharsRe = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+func (l *SungrowLocalizer) cachePath(version string, lang Language) string {
+	return filepath.Join(l.cacheDir, unsafePathCharsRe.ReplaceAllString(version, "_"), lang.String()+".properties")
+}
+
+func (l *SungrowLocalizer) readCache(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer f.Close()
+	return parseCodeMap(f)
+}
+
+// readLatestCache reads the most recently cached translations regardless of
+// the firmware version they were downloaded for.
+func (l *SungrowLocalizer) readLatestCache(lang Language) (map[string]string, error) {
+	if l.cacheDir == "" {
+		return nil, errors.New("cache disabled")
+	}
+
+	files, err := filepath.Glob(filepath.Join(l.cacheDir, "*", lang.String()+".properties"))
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var latest string
+	var latestModTime time.Time
+	for _, file := range files {
+		if fi, err := os.Stat(file); err == nil && fi.ModTime().After(latestModTime) {
+			latest, latestModTime = file, fi.ModTime()
 		}
-		i18nCode, name := parts[0], parts[1]
-		cm[i18nCode] = name
 	}
-	l.lm[lang] = cm
+	if latest == "" {
+		return nil, errors.New("no cached translations", errors.WithContext(errors.Context{"language": lang.String()}))
+	}
+	return l.readCache(latest)
+}
+
+func (l *SungrowLocalizer) writeCache(version string, lang Language, b []byte) error {
+	if l.cacheDir == "" || version == "" {
+		return nil
+	}
+	return writeFileAtomically(l.cachePath(version, lang), b)
+}
+
+// languagesCachePath is the file listing the languages discovered on the
+// firmware, one per line.
+func (l *SungrowLocalizer) languagesCachePath(version string) string {
+	return filepath.Join(l.cacheDir, unsafePathCharsRe.ReplaceAllString(version, "_"), "languages")
+}
+
+func (l *SungrowLocalizer) readLanguagesCache(version string) ([]Language, error) {
+	b, err := os.ReadFile(l.languagesCachePath(version))
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	langs := []Language{}
+	for _, lang := range strings.Fields(string(b)) {
+		langs = append(langs, Language(lang))
+	}
+	return langs, nil
+}
+
+func (l *SungrowLocalizer) writeLanguagesCache(version string, langs []Language) error {
+	if l.cacheDir == "" || version == "" {
+		return nil
+	}
+	var b strings.Builder
+	for _, lang := range langs {
+		b.WriteString(lang.String() + "\n")
+	}
+	return writeFileAtomically(l.languagesCachePath(version), []byte(b.String()))
+}
+
+// writeFileAtomically keeps a crash from leaving a truncated file behind.
+func writeFileAtomically(file string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return errors.Wrap(err)
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return errors.Wrap(err)
+	}
+	if err := os.Rename(tmp, file); err != nil {
+		return errors.Wrap(err)
+	}
+	return nil
+}
+
+func (l *SungrowLocalizer) readFallback(lang Language) (map[string]string, error) {
+	f, err := fallbackFS.Open(path.Join("i18n", lang.String()+".properties"))
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer f.Close()
+	return parseCodeMap(f)
+}
+
+func parseCodeMap(r io.Reader) (map[string]string, error) {
+	cm, err := properties.Parse(r)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
 	return cm, nil
 }
 
+// Catalog returns all translations of the language. While the inverter is
+// unreachable, they might stem from the cache or the embedded fallback, which
+// only covers a few codes.
+func (l *SungrowLocalizer) Catalog(lang Language) (map[string]string, error) {
+	cm, err := l.getCodeMap(l.match(lang))
+	if err != nil {
+		return nil, err
+	}
+	return maps.Clone(cm), nil
+}
+
+// InverterCatalog returns the translations of the language as shipped by the
+// inverter, read from the cache of the firmware or downloaded. Unlike Catalog,
+// it fails rather than falling back to other translations.
+func (l *SungrowLocalizer) InverterCatalog(lang Language) (map[string]string, error) {
+	lang = l.match(lang)
+	version := l.firmwareVersion()
+	if version != "" {
+		if codes, err := l.readCache(l.cachePath(version, lang)); err == nil {
+			return codes, nil
+		}
+	}
+	return l.download(version, lang)
+}
+
 func (l *SungrowLocalizer) Localize(i18nCode string, lang Language) (string, error) {
 	if lang == NoLanguage {
 		return i18nCode, nil
 	}
 
+	lang = l.match(lang)
 	cm, err := l.getCodeMap(lang)
 	if err != nil {
 		return "", err
 	}
 
-	// TODO: this likely needs to be adapted
-	parts := strings.Split(i18nCode, "%@")
-	i18nCode, args := parts[0], parts[1:]
-
+	i18nCode, args := splitArgs(i18nCode)
 	v, ok := cm[i18nCode]
 	if !ok {
 		return "", errors.New(
 			"unknown i18n code",
+			errors.WithCode(errors.UnknownI18NCodeCode),
 			errors.WithContext(errors.Context{"i18nCode": i18nCode, "language": lang.String()}),
 			errors.WithHTTPCode(http.StatusUnprocessableEntity),
+			errors.WithFault(errors.ClientFault),
 			errors.WithHTTPDetail(errors.ContextHTTPDetail),
 		)
 	}
 
-	if len(args) > 0 {
-		v = l.re.ReplaceAllStringFunc(v, func(a string) string {
-			// FIXME use proper group replace instead of indexing
-			return args[utils.Must(strconv.Atoi(a[1:len(a)-1]))]
-		})
-	}
+	return formatArgs(v, args), nil
+}
+
+var placeholderRe = regexp.MustCompile(`\{\s*(\d+)\s*(?:,[^{}]*)?\}`)
 
-	return v, nil
+// splitArgs splits the arguments off an i18n code of the form
+// "<i18nCode>%@<arg0>%@<arg1>...".
+func splitArgs(i18nCode string) (string, []string) {
+	// TODO: this likely needs to be adapted
+	parts := strings.Split(i18nCode, "%@")
+	return parts[0], parts[1:]
+}
+
+// formatArgs substitutes the {n} placeholders in v. Format styles like in
+// {0,number} are ignored and placeholders without a matching argument are
+// kept as is.
+func formatArgs(v string, args []string) string {
+	if len(args) == 0 {
+		return v
+	}
+	return placeholderRe.ReplaceAllStringFunc(v, func(placeholder string) string {
+		idx, err := strconv.Atoi(placeholderRe.FindStringSubmatch(placeholder)[1])
+		if err != nil || idx >= len(args) {
+			return placeholder
+		}
+		return args[idx]
+	})
 }
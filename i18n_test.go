@@ -0,0 +1,278 @@
+package redgiant
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/text/language"
+)
+
+func TestSungrowLocalizerCache(t *testing.T) {
+	var requests int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/i18n/en_US.properties" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("I18N_COMMON_BATTERY_SOC=Battery Level\n"))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	version := func() (string, error) { return "WINET/V1", nil }
+	newLocalizer := func() *SungrowLocalizer {
+		return NewSungrowLocalizer(u.Host, WithHTTPClient(srv.Client()), WithCacheDir(dir), WithFirmwareVersion(version))
+	}
+
+	name, err := newLocalizer().Localize("I18N_COMMON_BATTERY_SOC", EnglishLanguage)
+	require.NoError(t, err)
+	assert.Equal(t, "Battery Level", name)
+	assert.Equal(t, 1, requests)
+	assert.FileExists(t, filepath.Join(dir, "WINET_V1", "en_US.properties"))
+
+	// served from the cache without contacting the inverter
+	name, err = newLocalizer().Localize("I18N_COMMON_BATTERY_SOC", EnglishLanguage)
+	require.NoError(t, err)
+	assert.Equal(t, "Battery Level", name)
+	assert.Equal(t, 1, requests)
+
+	// a cache of a previous firmware is preferred over the embedded bundle
+	version = func() (string, error) { return "", os.ErrDeadlineExceeded }
+	srv.Close()
+	name, err = newLocalizer().Localize("I18N_COMMON_BATTERY_SOC", EnglishLanguage)
+	require.NoError(t, err)
+	assert.Equal(t, "Battery Level", name)
+}
+
+func TestSungrowLocalizerFallback(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	l := NewSungrowLocalizer(u.Host, WithHTTPClient(srv.Client()))
+	for _, lang := range []Language{ChineseLanguage, EnglishLanguage, GermanLanguage, DutchLanguage, PolishLanguage} {
+		t.Run(lang.String(), func(t *testing.T) {
+			name, err := l.Localize("I18N_COMMON_TOTAL_ACTIVE_POWER", lang)
+			require.NoError(t, err)
+			assert.NotEqual(t, "I18N_COMMON_TOTAL_ACTIVE_POWER", name)
+
+			// the fallback only covers a few common codes
+			_, err = l.Localize("I18N_CONFIG_KEY_4060", lang)
+			assert.Error(t, err)
+		})
+	}
+
+	// the fallback is not mistaken for the translations of the inverter
+	_, err = l.InverterCatalog(EnglishLanguage)
+	assert.Error(t, err)
+}
+
+func TestFormatArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		args     []string
+		expected string
+	}{
+		{name: "single", value: "String {0}", args: []string{"1"}, expected: "String 1"},
+		{name: "reordered", value: "{1} of {0}", args: []string{"a", "b"}, expected: "b of a"},
+		{name: "repeated", value: "{0}/{0}", args: []string{"a"}, expected: "a/a"},
+		{name: "format style", value: "{0,number,#} {1 }", args: []string{"1", "2"}, expected: "1 2"},
+		{name: "out of range", value: "{0} {2}", args: []string{"a"}, expected: "a {2}"},
+		{name: "overflow", value: "{99999999999999999999}", args: []string{"a"}, expected: "{99999999999999999999}"},
+		{name: "malformed", value: "{a} {-1} {0", args: []string{"a"}, expected: "{a} {-1} {0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, formatArgs(test.value, test.args))
+		})
+	}
+}
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    Language
+		shouldError bool
+	}{
+		{input: "", expected: NoLanguage},
+		{input: "de_DE", expected: GermanLanguage},
+		{input: "DE-de", expected: GermanLanguage},
+		{input: "de", expected: GermanLanguage},
+		{input: "de-AT", expected: "de_AT"},
+		{input: "ch_CN", expected: ChineseLanguage},
+		{input: "zh", expected: ChineseLanguage},
+		{input: "zh-Hant", expected: "zh_TW"},
+		{input: "fr-CA", expected: "fr_CA"},
+		{input: "und", shouldError: true},
+		{input: "not a language", shouldError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			actual, err := ParseLanguage(test.input)
+			if test.shouldError {
+				assert.Error(t, err)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, test.expected, actual)
+			}
+		})
+	}
+}
+
+// TestLanguageConstants guards the constants that predate Language being a
+// string. Code using them has to keep working unchanged.
+func TestLanguageConstants(t *testing.T) {
+	tests := []struct {
+		lang     Language
+		expected string
+	}{
+		{lang: NoLanguage, expected: ""},
+		{lang: ChineseLanguage, expected: "ch_CN"},
+		{lang: EnglishLanguage, expected: "en_US"},
+		{lang: GermanLanguage, expected: "de_DE"},
+		{lang: DutchLanguage, expected: "nl_NL"},
+		{lang: PolishLanguage, expected: "pl_PL"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.expected, func(t *testing.T) {
+			assert.Equal(t, test.expected, test.lang.String())
+			for _, s := range []string{test.expected, strings.ToUpper(test.expected)} {
+				lang, err := ParseLanguage(s)
+				require.NoError(t, err)
+				assert.Equal(t, test.lang, lang)
+			}
+		})
+	}
+
+	var zero Language
+	assert.Equal(t, NoLanguage, zero)
+}
+
+func TestMatchLanguage(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		expected       Language
+	}{
+		{acceptLanguage: "de-AT", expected: GermanLanguage},
+		{acceptLanguage: "nl-BE,nl;q=0.9", expected: DutchLanguage},
+		{acceptLanguage: "zh-CN", expected: ChineseLanguage},
+		{acceptLanguage: "it-IT,de;q=0.5", expected: GermanLanguage},
+		{acceptLanguage: "fr-CA", expected: "fr_FR"},
+		{acceptLanguage: "ja", expected: EnglishLanguage},
+		{acceptLanguage: "", expected: EnglishLanguage},
+	}
+
+	supported := append(slices.Clone(builtinLanguages), "fr_FR")
+	for _, test := range tests {
+		t.Run(test.acceptLanguage, func(t *testing.T) {
+			preferred, _, err := language.ParseAcceptLanguage(test.acceptLanguage)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, MatchLanguage(supported, preferred...))
+		})
+	}
+}
+
+func TestSungrowLocalizerLanguages(t *testing.T) {
+	shipped := []Language{EnglishLanguage, GermanLanguage, "fr_FR"}
+	var mu sync.Mutex
+	var probes, inFlight, maxInFlight int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Range") != "" {
+			mu.Lock()
+			probes++
+			inFlight++
+			maxInFlight = max(maxInFlight, inFlight)
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			}()
+			time.Sleep(time.Millisecond)
+		}
+		for _, lang := range shipped {
+			if r.URL.Path == "/i18n/"+lang.String()+".properties" {
+				http.ServeContent(w, r, "", time.Time{}, strings.NewReader("I18N_COMMON_BATTERY_SOC="+lang.String()+"\n"))
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	version := func() (string, error) { return "WINET/V1", nil }
+	newLocalizer := func() *SungrowLocalizer {
+		return NewSungrowLocalizer(u.Host, WithHTTPClient(srv.Client()), WithCacheDir(dir), WithFirmwareVersion(version))
+	}
+
+	l := newLocalizer()
+	assert.Equal(t, shipped, l.Languages())
+	assert.Equal(t, len(candidateLanguages), probes)
+	assert.LessOrEqual(t, maxInFlight, discoveryWorkers)
+
+	// the languages of the firmware are not discovered again
+	assert.Equal(t, shipped, newLocalizer().Languages())
+	assert.Equal(t, len(candidateLanguages), probes)
+
+	name, err := l.Localize("I18N_COMMON_BATTERY_SOC", "fr_BE")
+	require.NoError(t, err)
+	assert.Equal(t, "fr_FR", name)
+}
+
+func TestSungrowLocalizerConcurrentLoads(t *testing.T) {
+	var mu sync.Mutex
+	requests := map[string]int{}
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests[r.URL.Path]++
+		mu.Unlock()
+		// give concurrent loads the chance to pile up
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("I18N_COMMON_BATTERY_SOC=" + r.URL.Path + "\n"))
+	}))
+	defer srv.Close()
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	l := NewSungrowLocalizer(u.Host, WithHTTPClient(srv.Client()))
+	langs := []Language{EnglishLanguage, GermanLanguage, DutchLanguage}
+	require.NoError(t, l.Preload(GermanLanguage))
+
+	var wg sync.WaitGroup
+	for i := range 30 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lang := langs[i%len(langs)]
+			name, err := l.Localize("I18N_COMMON_BATTERY_SOC", lang)
+			assert.NoError(t, err)
+			assert.Equal(t, "/i18n/"+lang.String()+".properties", name)
+		}()
+	}
+	wg.Wait()
+
+	for _, lang := range langs {
+		assert.Equal(t, 1, requests["/i18n/"+lang.String()+".properties"], lang)
+	}
+}
@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pmeier/redgiant/internal/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Scope string
+
+const (
+	ReadScope   Scope = "read"
+	StreamScope Scope = "stream"
+	WriteScope  Scope = "write"
+	// AdminScope grants all other scopes.
+	AdminScope Scope = "admin"
+)
+
+type Principal struct {
+	Name   string
+	Method string
+	Scopes []Scope
+}
+
+func (p Principal) HasScope(s Scope) bool {
+	return slices.Contains(p.Scopes, s) || slices.Contains(p.Scopes, AdminScope)
+}
+
+// Authenticator extracts a principal from a request. If the request does not
+// carry credentials for the authenticator, ok is false. If it does, but they
+// are invalid, an error is returned.
+type Authenticator interface {
+	Authenticate(r *http.Request) (p Principal, ok bool, err error)
+	// Challenge is sent in the WWW-Authenticate header of unauthenticated responses.
+	Challenge() string
+}
+
+type APIKey struct {
+	Key    string
+	Name   string
+	Scopes []Scope
+}
+
+const (
+	APIKeyHeader = "X-API-Key"
+	APIKeyQuery  = "api_key"
+)
+
+type APIKeyAuthenticator struct {
+	keys    []APIKey
+	digests [][sha256.Size]byte
+}
+
+func NewAPIKeyAuthenticator(keys ...APIKey) *APIKeyAuthenticator {
+	digests := make([][sha256.Size]byte, 0, len(keys))
+	for _, k := range keys {
+		digests = append(digests, sha256.Sum256([]byte(k.Key)))
+	}
+	return &APIKeyAuthenticator{keys: keys, digests: digests}
+}
+
+func (a *APIKeyAuthenticator) Authenticate(r *http.Request) (Principal, bool, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key = r.URL.Query().Get(APIKeyQuery)
+	}
+	if key == "" {
+		return Principal{}, false, nil
+	}
+
+	// Comparing the digests instead of the keys themselves does not leak the
+	// length of the keys through the response time.
+	digest := sha256.Sum256([]byte(key))
+	for i, k := range a.keys {
+		if subtle.ConstantTimeCompare(digest[:], a.digests[i][:]) == 1 {
+			return Principal{Name: k.Name, Method: "apikey", Scopes: k.Scopes}, true, nil
+		}
+	}
+	return Principal{}, true, newUnauthorizedError("invalid API key")
+}
+
+// RedactURI replaces the API key in the query of a request URI, such that it
+// can be logged. If the query cannot be parsed, it is dropped altogether.
+func RedactURI(uri string) string {
+	path, query, ok := strings.Cut(uri, "?")
+	if !ok {
+		return uri
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return path
+	}
+	if !values.Has(APIKeyQuery) {
+		return uri
+	}
+	values.Set(APIKeyQuery, "REDACTED")
+	return path + "?" + values.Encode()
+}
+
+func (a *APIKeyAuthenticator) Challenge() string {
+	return ""
+}
+
+// ClientCertAuthenticator grants scopes to clients that presented a
+// certificate verified during the TLS handshake.
+type ClientCertAuthenticator struct {
+	scopes []Scope
+}
+
+func NewClientCertAuthenticator(scopes ...Scope) *ClientCertAuthenticator {
+	return &ClientCertAuthenticator{scopes: scopes}
+}
+
+func (a *ClientCertAuthenticator) Authenticate(r *http.Request) (Principal, bool, error) {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		return Principal{}, false, nil
+	}
+	return Principal{Name: r.TLS.PeerCertificates[0].Subject.CommonName, Method: "clientcert", Scopes: a.scopes}, true, nil
+}
+
+func (a *ClientCertAuthenticator) Challenge() string {
+	return ""
+}
+
+type BasicUser struct {
+	Username string
+	// PasswordHash is a bcrypt hash of the password.
+	PasswordHash string
+	Scopes       []Scope
+}
+
+type BasicAuthenticator struct {
+	users map[string]BasicUser
+	realm string
+}
+
+func NewBasicAuthenticator(realm string, users ...BasicUser) *BasicAuthenticator {
+	m := make(map[string]BasicUser, len(users))
+	for _, u := range users {
+		m[u.Username] = u
+	}
+	return &BasicAuthenticator{users: m, realm: realm}
+}
+
+// dummyHash is compared against for unknown users to avoid leaking which users
+// exist through the response time.
+var dummyHash = sync.OnceValue(func() []byte {
+	h, _ := bcrypt.GenerateFromPassword([]byte("redgiant"), bcrypt.DefaultCost)
+	return h
+})
+
+func (a *BasicAuthenticator) Authenticate(r *http.Request) (Principal, bool, error) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return Principal{}, false, nil
+	}
+
+	u, known := a.users[username]
+	hash := []byte(u.PasswordHash)
+	if !known {
+		hash = dummyHash()
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !known {
+		return Principal{}, true, newUnauthorizedError("invalid username or password")
+	}
+
+	return Principal{Name: u.Username, Method: "basic", Scopes: u.Scopes}, true, nil
+}
+
+func (a *BasicAuthenticator) Challenge() string {
+	return `Basic realm="` + a.realm + `"`
+}
+
+func newUnauthorizedError(msg string) *errors.RedgiantError {
+	return errors.New(
+		msg,
+		errors.WithCode(errors.UnauthorizedCode),
+		errors.WithHTTPCode(http.StatusUnauthorized),
+		errors.WithFault(errors.ClientFault),
+		errors.WithHTTPDetail(errors.MessageHTTPDetail),
+		errors.WithHiddenFrames(2),
+	)
+}
+
+const principalKey = "principal"
+
+// GetPrincipal returns the principal of an authenticated request.
+func GetPrincipal(c echo.Context) (Principal, bool) {
+	p, ok := c.Get(principalKey).(Principal)
+	return p, ok
+}
+
+// Authorizer checks requests against a list of authenticators. If the list is
+// empty, authentication is disabled and every request is granted all scopes.
+type Authorizer struct {
+	authenticators []Authenticator
+}
+
+func NewAuthorizer(authenticators ...Authenticator) *Authorizer {
+	return &Authorizer{authenticators: authenticators}
+}
+
+func (a *Authorizer) authenticate(r *http.Request) (Principal, error) {
+	for _, au := range a.authenticators {
+		p, ok, err := au.Authenticate(r)
+		if err != nil {
+			return Principal{}, err
+		} else if ok {
+			return p, nil
+		}
+	}
+	return Principal{}, newUnauthorizedError("authentication required")
+}
+
+func (a *Authorizer) challenges() string {
+	var cs []string
+	for _, au := range a.authenticators {
+		if c := au.Challenge(); c != "" {
+			cs = append(cs, c)
+		}
+	}
+	return strings.Join(cs, ", ")
+}
+
+// Require wraps a handler such that it is only called for requests that were
+// granted the scope.
+func (a *Authorizer) Require(scope Scope, next echo.HandlerFunc) echo.HandlerFunc {
+	if len(a.authenticators) == 0 {
+		return next
+	}
+
+	return func(c echo.Context) error {
+		p, err := a.authenticate(c.Request())
+		if err != nil {
+			if cs := a.challenges(); cs != "" {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, cs)
+			}
+			return err
+		}
+
+		if !p.HasScope(scope) {
+			return errors.New(
+				"insufficient scope",
+				errors.WithCode(errors.InsufficientScopeCode),
+				errors.WithContext(errors.Context{"scope": scope}),
+				errors.WithHTTPCode(http.StatusForbidden),
+				errors.WithFault(errors.ClientFault),
+				errors.WithHTTPDetail(errors.ContextHTTPDetail),
+			)
+		}
+
+		c.Set(principalKey, p)
+		return next(c)
+	}
+}
@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func signHS256(t *testing.T, secret string, claims string) string {
+	enc := base64.RawURLEncoding
+	s := enc.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims))
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write([]byte(s))
+	return s + "." + enc.EncodeToString(m.Sum(nil))
+}
+
+func TestAuthorizer(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("pw"), bcrypt.MinCost)
+	require.NoError(t, err)
+	jwt, err := NewJWTAuthenticator(JWTOptions{Secret: []byte("secret"), Audience: "redgiant"})
+	require.NoError(t, err)
+
+	a := NewAuthorizer(
+		NewAPIKeyAuthenticator(APIKey{Key: "reader", Scopes: []Scope{ReadScope}}, APIKey{Key: "root", Scopes: []Scope{AdminScope}}),
+		jwt,
+		NewBasicAuthenticator("redgiant", BasicUser{Username: "user", PasswordHash: string(hash), Scopes: []Scope{ReadScope}}),
+	)
+
+	tests := []struct {
+		name     string
+		scope    Scope
+		prepare  func(r *http.Request)
+		expected int
+	}{
+		{name: "no credentials", scope: ReadScope, prepare: func(r *http.Request) {}, expected: http.StatusUnauthorized},
+		{name: "api key header", scope: ReadScope, prepare: func(r *http.Request) { r.Header.Set(APIKeyHeader, "reader") }, expected: http.StatusOK},
+		{name: "api key query", scope: ReadScope, prepare: func(r *http.Request) { r.URL.RawQuery = "api_key=reader" }, expected: http.StatusOK},
+		{name: "invalid api key", scope: ReadScope, prepare: func(r *http.Request) { r.Header.Set(APIKeyHeader, "wrong") }, expected: http.StatusUnauthorized},
+		{name: "insufficient scope", scope: WriteScope, prepare: func(r *http.Request) { r.Header.Set(APIKeyHeader, "reader") }, expected: http.StatusForbidden},
+		{name: "admin", scope: WriteScope, prepare: func(r *http.Request) { r.Header.Set(APIKeyHeader, "root") }, expected: http.StatusOK},
+		{name: "basic", scope: ReadScope, prepare: func(r *http.Request) { r.SetBasicAuth("user", "pw") }, expected: http.StatusOK},
+		{name: "basic wrong password", scope: ReadScope, prepare: func(r *http.Request) { r.SetBasicAuth("user", "wrong") }, expected: http.StatusUnauthorized},
+		{name: "basic unknown user", scope: ReadScope, prepare: func(r *http.Request) { r.SetBasicAuth("nobody", "pw") }, expected: http.StatusUnauthorized},
+		{
+			name:  "jwt",
+			scope: StreamScope,
+			prepare: func(r *http.Request) {
+				r.Header.Set(echo.HeaderAuthorization, "Bearer "+signHS256(t, "secret", `{"sub":"me","aud":"redgiant","scope":"read stream","exp":4102444800}`))
+			},
+			expected: http.StatusOK,
+		},
+		{
+			name:  "jwt expired",
+			scope: ReadScope,
+			prepare: func(r *http.Request) {
+				r.Header.Set(echo.HeaderAuthorization, "Bearer "+signHS256(t, "secret", `{"aud":"redgiant","scope":"read","exp":1}`))
+			},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:  "jwt wrong secret",
+			scope: ReadScope,
+			prepare: func(r *http.Request) {
+				r.Header.Set(echo.HeaderAuthorization, "Bearer "+signHS256(t, "other", `{"aud":"redgiant","scope":"read","exp":4102444800}`))
+			},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:  "jwt without exp",
+			scope: ReadScope,
+			prepare: func(r *http.Request) {
+				r.Header.Set(echo.HeaderAuthorization, "Bearer "+signHS256(t, "secret", `{"aud":"redgiant","scope":"read"}`))
+			},
+			expected: http.StatusUnauthorized,
+		},
+		{
+			name:  "jwt wrong audience",
+			scope: ReadScope,
+			prepare: func(r *http.Request) {
+				r.Header.Set(echo.HeaderAuthorization, "Bearer "+signHS256(t, "secret", `{"aud":"other","scope":"read","exp":4102444800}`))
+			},
+			expected: http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			e := echo.New()
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			test.prepare(r)
+			w := httptest.NewRecorder()
+			c := e.NewContext(r, w)
+
+			err := a.Require(test.scope, func(c echo.Context) error {
+				return c.NoContent(http.StatusOK)
+			})(c)
+
+			status := w.Code
+			if err != nil {
+				var coder interface{ HTTPCode() int }
+				require.ErrorAs(t, err, &coder)
+				status = coder.HTTPCode()
+			}
+			assert.Equal(t, test.expected, status)
+		})
+	}
+}
+
+func TestRedactURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected string
+	}{
+		{uri: "/api/state", expected: "/api/state"},
+		{uri: "/api/state?lang=en", expected: "/api/state?lang=en"},
+		{uri: "/api/state?api_key=secret", expected: "/api/state?api_key=REDACTED"},
+		{uri: "/api/state?lang=en&api_key=secret", expected: "/api/state?api_key=REDACTED&lang=en"},
+		{uri: "/api/state?api_key=secret;%zz", expected: "/api/state"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.uri, func(t *testing.T) {
+			assert.Equal(t, test.expected, RedactURI(test.uri))
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/labstack/echo/v4"
+)
+
+type JWTOptions struct {
+	// Secret is the shared secret for the HS* algorithms.
+	Secret []byte
+	// PublicKey is a PEM encoded public key or certificate for the RS* and ES*
+	// algorithms.
+	PublicKey []byte
+	Issuer    string
+	Audience  string
+	// ScopeClaim is the claim holding the scopes of the token, either as space
+	// separated string or as list.
+	ScopeClaim string
+	// Leeway is the tolerated clock skew when validating exp and nbf.
+	Leeway time.Duration
+}
+
+// JWTAuthenticator validates bearer tokens. Only the algorithms matching the
+// configured key are accepted, which rules out algorithm confusion attacks.
+// Tokens without an exp claim are rejected.
+type JWTAuthenticator struct {
+	opts       JWTOptions
+	algorithms []string
+	publicKey  crypto.PublicKey
+}
+
+func NewJWTAuthenticator(opts JWTOptions) (*JWTAuthenticator, error) {
+	if opts.ScopeClaim == "" {
+		opts.ScopeClaim = "scope"
+	}
+	a := &JWTAuthenticator{opts: opts}
+
+	if len(opts.Secret) > 0 {
+		a.algorithms = append(a.algorithms, "HS256", "HS384", "HS512")
+	}
+	if len(opts.PublicKey) > 0 {
+		key, err := parsePublicKey(opts.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+		a.publicKey = key
+		switch key.(type) {
+		case *rsa.PublicKey:
+			a.algorithms = append(a.algorithms, "RS256", "RS384", "RS512")
+		case *ecdsa.PublicKey:
+			a.algorithms = append(a.algorithms, "ES256", "ES384", "ES512")
+		default:
+			return nil, fmt.Errorf("unsupported public key type %T", key)
+		}
+	}
+	if len(a.algorithms) == 0 {
+		return nil, errors.New("neither secret nor public key configured")
+	}
+
+	return a, nil
+}
+
+func parsePublicKey(b []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	switch block.Type {
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	case "RSA PUBLIC KEY":
+		return x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		return x509.ParsePKIXPublicKey(block.Bytes)
+	}
+}
+
+func (a *JWTAuthenticator) key(t *jwt.Token) (any, error) {
+	if _, ok := t.Method.(*jwt.SigningMethodHMAC); ok {
+		return a.opts.Secret, nil
+	}
+	return a.publicKey, nil
+}
+
+func (a *JWTAuthenticator) parse(token string) (jwt.MapClaims, error) {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods(a.algorithms),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(a.opts.Leeway),
+	}
+	if a.opts.Issuer != "" {
+		opts = append(opts, jwt.WithIssuer(a.opts.Issuer))
+	}
+	if a.opts.Audience != "" {
+		opts = append(opts, jwt.WithAudience(a.opts.Audience))
+	}
+
+	claims := jwt.MapClaims{}
+	if _, err := jwt.NewParser(opts...).ParseWithClaims(token, claims, a.key); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func scopes(claims jwt.MapClaims, name string) []Scope {
+	var ss []string
+	switch v := claims[name].(type) {
+	case string:
+		ss = strings.Fields(v)
+	case []any:
+		for _, s := range v {
+			if s, ok := s.(string); ok {
+				ss = append(ss, s)
+			}
+		}
+	}
+
+	scopes := make([]Scope, 0, len(ss))
+	for _, s := range ss {
+		scopes = append(scopes, Scope(s))
+	}
+	return scopes
+}
+
+func (a *JWTAuthenticator) Authenticate(r *http.Request) (Principal, bool, error) {
+	token, ok := strings.CutPrefix(r.Header.Get(echo.HeaderAuthorization), "Bearer ")
+	if !ok {
+		return Principal{}, false, nil
+	}
+
+	claims, err := a.parse(strings.TrimSpace(token))
+	if err != nil {
+		return Principal{}, true, newUnauthorizedError("invalid token: " + err.Error())
+	}
+
+	sub, _ := claims.GetSubject()
+	return Principal{Name: sub, Method: "jwt", Scopes: scopes(claims, a.opts.ScopeClaim)}, true, nil
+}
+
+func (a *JWTAuthenticator) Challenge() string {
+	return "Bearer"
+}
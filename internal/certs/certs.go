@@ -0,0 +1,159 @@
+package certs
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pmeier/redgiant/internal/config"
+)
+
+// LoadOrGenerate loads the key pair from disk. If neither file exists and
+// selfSigned is set, a self-signed certificate valid for the hosts is
+// generated and persisted, so clients can pin it across restarts.
+func LoadOrGenerate(certFile string, keyFile string, selfSigned bool, hosts ...string) (tls.Certificate, error) {
+	_, certErr := os.Stat(certFile)
+	_, keyErr := os.Stat(keyFile)
+	if selfSigned && errors.Is(certErr, os.ErrNotExist) && errors.Is(keyErr, os.ErrNotExist) {
+		if err := generateSelfSigned(certFile, keyFile, hosts); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+func generateSelfSigned(certFile string, keyFile string, hosts []string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	tpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "redgiant", Organization: []string{"redgiant"}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, h := range append([]string{"localhost", "127.0.0.1", "::1"}, hosts...) {
+		if ip := net.ParseIP(h); ip != nil {
+			if !ip.IsUnspecified() {
+				tpl.IPAddresses = append(tpl.IPAddresses, ip)
+			}
+		} else if h != "" {
+			tpl.DNSNames = append(tpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tpl, tpl, &key.PublicKey, key)
+	if err != nil {
+		return err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	if err := writePEM(certFile, "CERTIFICATE", der, 0o644); err != nil {
+		return err
+	}
+	return writePEM(keyFile, "PRIVATE KEY", keyDER, 0o600)
+}
+
+func writePEM(path string, typ string, der []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), perm)
+}
+
+func LoadCertPool(files ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", f)
+		}
+	}
+	return pool, nil
+}
+
+func ServerTLSConfig(c config.ServerConfig) (*tls.Config, error) {
+	if !c.TLS.Enabled {
+		return nil, nil
+	}
+
+	cert, err := LoadOrGenerate(c.TLS.CertFile, c.TLS.KeyFile, c.TLS.SelfSigned, c.Host)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+
+	switch c.TLS.ClientAuth {
+	case config.NoClientAuth:
+		return tc, nil
+	case config.OptionalClientAuth:
+		tc.ClientAuth = tls.VerifyClientCertIfGiven
+	case config.RequiredClientAuth:
+		tc.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	if c.TLS.ClientCAFile == "" {
+		return nil, errors.New("client authentication requires a client CA file")
+	}
+	if tc.ClientCAs, err = LoadCertPool(c.TLS.ClientCAFile); err != nil {
+		return nil, err
+	}
+	return tc, nil
+}
+
+// ClientTLSConfig returns the configuration used to talk to the server, e.g.
+// for health checks. If no CA is configured, the server certificate itself is
+// trusted, which covers the self-signed case.
+func ClientTLSConfig(c config.ServerConfig) (*tls.Config, error) {
+	if !c.TLS.Enabled {
+		return nil, nil
+	}
+
+	caFile := c.TLS.CAFile
+	if caFile == "" {
+		caFile = c.TLS.CertFile
+	}
+	pool, err := LoadCertPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+	tc := &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+
+	if c.TLS.ClientCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.TLS.ClientCertFile, c.TLS.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tc.Certificates = []tls.Certificate{cert}
+	}
+	return tc, nil
+}
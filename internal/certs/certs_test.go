@@ -0,0 +1,138 @@
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pmeier/redgiant/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOrGenerate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls", "cert.pem"), filepath.Join(dir, "tls", "key.pem")
+
+	_, err := LoadOrGenerate(certFile, keyFile, false)
+	assert.Error(t, err)
+
+	cert, err := LoadOrGenerate(certFile, keyFile, true, "redgiant.local", "192.168.1.2", "0.0.0.0")
+	require.NoError(t, err)
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"localhost", "redgiant.local"}, leaf.DNSNames)
+	var ips []string
+	for _, ip := range leaf.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	assert.ElementsMatch(t, []string{"127.0.0.1", "::1", "192.168.1.2"}, ips)
+
+	info, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	// the generated certificate is persisted rather than regenerated
+	reloaded, err := LoadOrGenerate(certFile, keyFile, true)
+	require.NoError(t, err)
+	assert.Equal(t, cert.Certificate, reloaded.Certificate)
+}
+
+func TestServerTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	tc := func(clientAuth config.ClientAuth, clientCAFile string) config.TLSConfig {
+		return config.TLSConfig{
+			Enabled:      true,
+			CertFile:     filepath.Join(dir, "cert.pem"),
+			KeyFile:      filepath.Join(dir, "key.pem"),
+			SelfSigned:   true,
+			ClientAuth:   clientAuth,
+			ClientCAFile: clientCAFile,
+		}
+	}
+
+	tests := []struct {
+		name               string
+		tls                config.TLSConfig
+		expectedNil        bool
+		expectedClientAuth tls.ClientAuthType
+		shouldError        bool
+	}{
+		{name: "disabled", tls: config.TLSConfig{}, expectedNil: true},
+		{name: "self-signed", tls: tc(config.NoClientAuth, ""), expectedClientAuth: tls.NoClientCert},
+		{name: "optional client auth", tls: tc(config.OptionalClientAuth, filepath.Join(dir, "cert.pem")), expectedClientAuth: tls.VerifyClientCertIfGiven},
+		{name: "required client auth", tls: tc(config.RequiredClientAuth, filepath.Join(dir, "cert.pem")), expectedClientAuth: tls.RequireAndVerifyClientCert},
+		{name: "client auth without CA", tls: tc(config.RequiredClientAuth, ""), shouldError: true},
+		{name: "missing certificate", tls: config.TLSConfig{Enabled: true, CertFile: filepath.Join(dir, "missing.pem"), KeyFile: filepath.Join(dir, "missing-key.pem")}, shouldError: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, err := ServerTLSConfig(config.ServerConfig{Host: "localhost", TLS: test.tls})
+			if test.shouldError {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if test.expectedNil {
+				assert.Nil(t, c)
+				return
+			}
+			assert.Len(t, c.Certificates, 1)
+			assert.Equal(t, uint16(tls.VersionTLS12), c.MinVersion)
+			assert.Equal(t, test.expectedClientAuth, c.ClientAuth)
+			assert.Equal(t, test.expectedClientAuth != tls.NoClientCert, c.ClientCAs != nil)
+		})
+	}
+}
+
+func TestClientTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	sc := config.ServerConfig{Host: "localhost", TLS: config.TLSConfig{
+		Enabled:    true,
+		CertFile:   filepath.Join(dir, "cert.pem"),
+		KeyFile:    filepath.Join(dir, "key.pem"),
+		SelfSigned: true,
+	}}
+
+	c, err := ClientTLSConfig(config.ServerConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, c)
+
+	stc, err := ServerTLSConfig(sc)
+	require.NoError(t, err)
+	// the client trusts the self-signed server certificate without a CA file
+	c, err = ClientTLSConfig(sc)
+	require.NoError(t, err)
+	assert.Empty(t, c.Certificates)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", stc)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	conn, err := tls.Dial("tcp", net.JoinHostPort("localhost", port), c)
+	require.NoError(t, err)
+	conn.Close()
+
+	_, err = tls.Dial("tcp", net.JoinHostPort("localhost", port), &tls.Config{})
+	assert.Error(t, err)
+
+	sc.TLS.ClientCertFile, sc.TLS.ClientKeyFile = sc.TLS.CertFile, sc.TLS.KeyFile
+	c, err = ClientTLSConfig(sc)
+	require.NoError(t, err)
+	assert.Len(t, c.Certificates, 1)
+}
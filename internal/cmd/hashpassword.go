@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/term"
+)
+
+var hashPasswordCmd = &cobra.Command{
+	Use:   "hash-password",
+	Short: "Hash a password for HTTP Basic authentication",
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := hashPassword(); err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func hashPassword() error {
+	var password []byte
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		p, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return err
+		}
+		password = p
+	} else {
+		p, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && p == "" {
+			return err
+		}
+		password = []byte(strings.TrimRight(p, "\r\n"))
+	}
+
+	hash, err := bcrypt.GenerateFromPassword(password, bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(hash))
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(hashPasswordCmd)
+}
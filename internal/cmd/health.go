@@ -1,17 +1,24 @@
 package cmd
 
 import (
+	"github.com/pmeier/redgiant/internal/config"
 	"github.com/pmeier/redgiant/internal/health"
 
 	"github.com/spf13/cobra"
 )
 
+var healthOpts health.RunOptions
+
 var healthCmd = &cobra.Command{
 	Use:   "health",
 	Short: "Check the health of the REST API",
-	Run:   runFunc(health.Run),
+	Run: runFunc(func(c config.Config) error {
+		return health.Run(c, healthOpts)
+	}),
 }
 
 func init() {
+	healthCmd.Flags().BoolVar(&healthOpts.Ready, "ready", false, "check readiness, i.e. whether the inverter is connected, instead of liveness")
+	healthCmd.Flags().BoolVar(&healthOpts.JSON, "json", false, "print the status reported by the server as JSON")
 	rootCmd.AddCommand(healthCmd)
 }
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/config"
+	"github.com/pmeier/redgiant/internal/properties"
+	"github.com/rs/zerolog"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var i18nCmd = &cobra.Command{
+	Use:   "i18n",
+	Short: "Manage translations of measurement names",
+}
+
+var i18nDumpOpts struct {
+	format    string
+	outputDir string
+}
+
+var i18nDumpCmd = &cobra.Command{
+	Use:   "dump [LANG...]",
+	Short: "Export the translations shipped by the inverter",
+	Long: `Export the translations shipped by the inverter, e.g. as a starting point
+for overrides. A single language is written to stdout unless an output
+directory is given. Without languages, all languages of the inverter are
+exported, which requires an output directory. Fails if the translations
+cannot be read from the inverter or the cache of its firmware, rather than
+exporting the bundled fallback.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runFunc(func(c config.Config) error {
+			return dumpTranslations(c, args)
+		})(cmd, args)
+	},
+}
+
+func dumpTranslations(c config.Config, args []string) error {
+	var write func(io.Writer, map[string]string) error
+	switch i18nDumpOpts.format {
+	case "properties":
+		write = properties.Write
+	case "yaml":
+		write = func(w io.Writer, m map[string]string) error {
+			return yaml.NewEncoder(w).Encode(m)
+		}
+	default:
+		return fmt.Errorf("unknown format %q", i18nDumpOpts.format)
+	}
+
+	logger := zerolog.New(c.Logging.Format.Writer()).With().Timestamp().Logger().Level(c.Logging.Level)
+
+	// The firmware version keys the cache like for the server, but reading it
+	// requires a login, so the inverter is only connected if it is needed.
+	sg := redgiant.NewSungrow(
+		c.Sungrow.Host,
+		c.Sungrow.Username,
+		c.Sungrow.Password,
+		redgiant.WithLogger(logger),
+		redgiant.WithReconnect(0),
+	)
+	rg := redgiant.NewRedgiant(sg, redgiant.WithLogger(logger), redgiant.WithConnectionMode(redgiant.OnDemandConnectionMode, 0))
+	defer rg.Close()
+	version := sync.OnceValues(func() (string, error) {
+		a, err := rg.About()
+		if err != nil {
+			logger.Warn().Err(err).Msg("unable to determine firmware version, translations are not cached")
+			return "", err
+		}
+		return a.Version, nil
+	})
+
+	l := redgiant.NewSungrowLocalizer(
+		c.Sungrow.Host,
+		redgiant.WithLogger(logger),
+		redgiant.WithCacheDir(c.I18N.CacheDir),
+		redgiant.WithFirmwareVersion(version),
+	)
+
+	supported := l.Languages()
+	var langs []redgiant.Language
+	for _, arg := range args {
+		lang, err := redgiant.ParseLanguage(arg)
+		if err != nil {
+			return err
+		}
+		// export what is actually used for the language, e.g. de_DE for de-AT
+		langs = append(langs, redgiant.MatchLanguage(supported, lang.Tag()))
+	}
+	if len(langs) == 0 {
+		langs = supported
+	}
+
+	if i18nDumpOpts.outputDir == "" {
+		if len(langs) != 1 {
+			return fmt.Errorf("exporting %d languages requires an output directory", len(langs))
+		}
+		cm, err := l.InverterCatalog(langs[0])
+		if err != nil {
+			return err
+		}
+		return write(os.Stdout, cm)
+	}
+
+	if err := os.MkdirAll(i18nDumpOpts.outputDir, 0o755); err != nil {
+		return err
+	}
+	for _, lang := range langs {
+		cm, err := l.InverterCatalog(lang)
+		if err != nil {
+			return err
+		}
+
+		file := filepath.Join(i18nDumpOpts.outputDir, lang.String()+"."+i18nDumpOpts.format)
+		f, err := os.Create(file)
+		if err != nil {
+			return err
+		}
+		err = write(f, cm)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, file)
+	}
+	return nil
+}
+
+func init() {
+	i18nDumpCmd.Flags().StringVar(&i18nDumpOpts.format, "format", "properties", "output format, either properties or yaml")
+	i18nDumpCmd.Flags().StringVarP(&i18nDumpOpts.outputDir, "output-dir", "o", "", "directory to write one file per language to")
+	i18nCmd.AddCommand(i18nDumpCmd)
+	rootCmd.AddCommand(i18nCmd)
+}
@@ -10,10 +10,13 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/sprig/v3"
 	"github.com/go-playground/validator/v10"
 	"github.com/go-viper/mapstructure/v2"
+	"github.com/pmeier/redgiant"
+	rgerrors "github.com/pmeier/redgiant/internal/errors"
 	"github.com/rs/zerolog"
 	"github.com/spf13/viper"
 	"golang.org/x/term"
@@ -72,9 +75,100 @@ func (f LoggingFormat) Writer() io.Writer {
 	}
 }
 
+type ClientAuth uint8
+
+const (
+	NoClientAuth ClientAuth = iota
+	OptionalClientAuth
+	RequiredClientAuth
+)
+
+func (ca ClientAuth) String() string {
+	switch ca {
+	case NoClientAuth:
+		return "none"
+	case OptionalClientAuth:
+		return "optional"
+	case RequiredClientAuth:
+		return "required"
+	default:
+		return strconv.Itoa(int(ca))
+	}
+}
+
+func ParseClientAuth(clientAuthStr string) (ClientAuth, error) {
+	for _, ca := range []ClientAuth{
+		NoClientAuth,
+		OptionalClientAuth,
+		RequiredClientAuth,
+	} {
+		if strings.EqualFold(clientAuthStr, ca.String()) {
+			return ca, nil
+		}
+	}
+	return NoClientAuth, errors.New("unknown client authentication mode")
+}
+
+type TLSConfig struct {
+	Enabled  bool
+	CertFile string `validate:"required_if=Enabled true"`
+	KeyFile  string `validate:"required_if=Enabled true"`
+	// SelfSigned generates and persists a self-signed certificate if CertFile
+	// and KeyFile do not exist yet.
+	SelfSigned bool
+	// CAFile is trusted when connecting to the server, e.g. by the health
+	// check. Defaults to CertFile.
+	CAFile string
+	// ClientAuth selects whether client certificates signed by ClientCAFile are
+	// verified. Verified clients are granted ClientCertScopes.
+	ClientAuth       ClientAuth
+	ClientCAFile     string
+	ClientCertScopes []string `validate:"dive,oneof=read stream write admin"`
+	// ClientCertFile and ClientKeyFile are presented by the health check. They
+	// are only needed if client certificates are required.
+	ClientCertFile string
+	ClientKeyFile  string
+}
+
+type APIKeyConfig struct {
+	Name   string
+	Key    string   `validate:"required"`
+	Scopes []string `validate:"dive,oneof=read stream write admin"`
+}
+
+type BasicUserConfig struct {
+	Username     string   `validate:"required"`
+	PasswordHash string   `validate:"required"`
+	Scopes       []string `validate:"dive,oneof=read stream write admin"`
+}
+
+type JWTConfig struct {
+	Secret        string
+	PublicKeyFile string
+	Issuer        string
+	Audience      string
+	ScopeClaim    string
+	Leeway        time.Duration
+}
+
+// AuthConfig configures authentication of the API. It is enabled as soon as
+// any credentials are configured.
+type AuthConfig struct {
+	APIKeys []APIKeyConfig    `validate:"dive"`
+	Basic   []BasicUserConfig `validate:"dive"`
+	JWT     JWTConfig
+}
+
 type ServerConfig struct {
 	Host string
 	Port uint
+	TLS  TLSConfig
+	Auth AuthConfig
+	// ShutdownTimeout limits how long in-flight requests are drained on shutdown.
+	ShutdownTimeout time.Duration
+	// ErrorFormat selects RFC 7807 problem details or, for clients that rely on
+	// it, the legacy shape of error responses.
+	ErrorFormat rgerrors.Format
 }
 
 type LoggingConfig struct {
@@ -87,12 +181,93 @@ type SungrowConfig struct {
 	Username       string
 	Password       string
 	ReconnectTries uint
+	// MinConnectBackoff and MaxConnectBackoff bound the wait between
+	// connection attempts while the inverter is unreachable.
+	MinConnectBackoff time.Duration `validate:"gt=0"`
+	MaxConnectBackoff time.Duration `validate:"gtefield=MinConnectBackoff"`
+	// ConnectionMode selects whether the session with the inverter is kept
+	// open or only established on demand and closed after IdleTimeout.
+	ConnectionMode redgiant.ConnectionMode
+	IdleTimeout    time.Duration `validate:"gt=0"`
+}
+
+type PollConfig struct {
+	Interval time.Duration
+	Language redgiant.Language
+}
+
+type MQTTConfig struct {
+	Enabled           bool
+	Broker            string `validate:"required_if=Enabled true"`
+	ClientID          string
+	Username          string
+	Password          string
+	TopicPrefix       string
+	Discovery         bool
+	DiscoveryPrefix   string
+	QoS               uint8 `validate:"max=1"`
+	Retain            bool
+	KeepAlive         time.Duration
+	ReconnectInterval time.Duration
+}
+
+type InfluxConfig struct {
+	Enabled       bool
+	URL           string `validate:"required_if=Enabled true"`
+	Token         string
+	Org           string
+	Bucket        string
+	Tags          []string `validate:"dive,oneof=serial device model metric unit"`
+	ExtraTags     map[string]string
+	BatchSize     int `validate:"min=1"`
+	FlushInterval time.Duration
+	BufferSize    int
+	Timeout       time.Duration
+}
+
+type HistoryConfig struct {
+	Enabled   bool
+	Directory string `validate:"required_if=Enabled true"`
+	// Retentions of the individual tiers. Zero keeps data forever.
+	RawRetention        time.Duration
+	FiveMinuteRetention time.Duration
+	HourlyRetention     time.Duration
+	DailyRetention      time.Duration
+}
+
+type I18NConfig struct {
+	// CacheDir persists the translations downloaded from the inverter, so they
+	// are available while it is unreachable. Empty disables the cache, leaving
+	// only the embedded fallback, which covers a few common codes.
+	CacheDir string
+	// Preload are loaded at startup in addition to the language of the poller,
+	// so the first request in each language does not have to wait for them.
+	Preload []redgiant.Language
+	// OverrideDir holds translations that take precedence over the ones of the
+	// inverter, see redgiant.LoadOverrides. Empty disables overrides.
+	OverrideDir string
+}
+
+type HealthConfig struct {
+	// MaxHeartbeatAge is the maximum time since the last heartbeat for the
+	// server to be ready. Zero disables the check.
+	MaxHeartbeatAge time.Duration
+	// MaxReadAge is the maximum time since the last data read for the server
+	// to be ready. Zero defaults to three poll intervals and a negative value
+	// disables the check. It is skipped while there is nothing to poll for.
+	MaxReadAge time.Duration
 }
 
 type Config struct {
 	Server  ServerConfig
+	Health  HealthConfig
 	Logging LoggingConfig
 	Sungrow SungrowConfig
+	Poll    PollConfig
+	MQTT    MQTTConfig
+	Influx  InfluxConfig
+	History HistoryConfig
+	I18N    I18NConfig
 }
 
 func Load() (*Config, error) {
@@ -119,6 +294,12 @@ func Load() (*Config, error) {
 			stringTemplatingHookFunc(),
 			stringToZerologLevelHookFunc(),
 			stringToLoggingFormatHookFunc(),
+			stringToLanguageHookFunc(),
+			stringToClientAuthHookFunc(),
+			stringToConnectionModeHookFunc(),
+			stringToErrorFormatHookFunc(),
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
 		)
 	}); err != nil {
 		return nil, err
@@ -135,17 +316,63 @@ func Load() (*Config, error) {
 func loadDefaults(v *viper.Viper) error {
 	dc := Config{
 		Server: ServerConfig{
-			Host: "127.0.0.1",
-			Port: 8000,
+			Host:            "127.0.0.1",
+			Port:            8000,
+			ShutdownTimeout: 10 * time.Second,
+			ErrorFormat:     rgerrors.ProblemFormat,
+			Auth: AuthConfig{
+				JWT: JWTConfig{
+					ScopeClaim: "scope",
+					Leeway:     time.Minute,
+				},
+			},
+		},
+		Health: HealthConfig{
+			MaxHeartbeatAge: 30 * time.Second,
+			MaxReadAge:      0,
 		},
 		Logging: LoggingConfig{
 			Level:  zerolog.InfoLevel,
 			Format: AutoLoggingFormat,
 		},
 		Sungrow: SungrowConfig{
-			Username:       "user",
-			Password:       "pw1111",
-			ReconnectTries: 3,
+			Username:          "user",
+			Password:          "pw1111",
+			ReconnectTries:    3,
+			MinConnectBackoff: 5 * time.Second,
+			MaxConnectBackoff: 5 * time.Minute,
+			ConnectionMode:    redgiant.PersistentConnectionMode,
+			IdleTimeout:       5 * time.Minute,
+		},
+		Poll: PollConfig{
+			Interval: 10 * time.Second,
+			Language: redgiant.EnglishLanguage,
+		},
+		MQTT: MQTTConfig{
+			ClientID:          "redgiant",
+			TopicPrefix:       "redgiant",
+			Discovery:         true,
+			DiscoveryPrefix:   "homeassistant",
+			QoS:               0,
+			KeepAlive:         30 * time.Second,
+			ReconnectInterval: 30 * time.Second,
+		},
+		Influx: InfluxConfig{
+			Tags:          []string{"serial", "device", "model", "metric", "unit"},
+			BatchSize:     1000,
+			FlushInterval: 10 * time.Second,
+			BufferSize:    100_000,
+			Timeout:       10 * time.Second,
+		},
+		History: HistoryConfig{
+			RawRetention:        48 * time.Hour,
+			FiveMinuteRetention: 30 * 24 * time.Hour,
+			HourlyRetention:     365 * 24 * time.Hour,
+			DailyRetention:      0,
+		},
+		I18N: I18NConfig{
+			// unset keys are unknown to viper and thus ignored in env vars
+			Preload: []redgiant.Language{},
 		},
 	}
 
@@ -247,3 +474,79 @@ func stringToLoggingFormatHookFunc() mapstructure.DecodeHookFuncType {
 		return ParseLoggingFormat(data.(string))
 	}
 }
+
+func stringToLanguageHookFunc() mapstructure.DecodeHookFuncType {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data any,
+	) (any, error) {
+		if f.Kind() != reflect.String {
+			return data, nil
+		}
+
+		// data might already be a Language, which is a string as well
+		str := reflect.ValueOf(data).String()
+		switch t {
+		case reflect.TypeOf(redgiant.NoLanguage):
+			return redgiant.ParseLanguage(str)
+		case reflect.TypeOf([]redgiant.Language{}):
+			// mapstructure.StringToSliceHookFunc only handles []string
+			langs := []redgiant.Language{}
+			for _, langStr := range strings.Split(str, ",") {
+				if langStr = strings.TrimSpace(langStr); langStr == "" {
+					continue
+				}
+				lang, err := redgiant.ParseLanguage(langStr)
+				if err != nil {
+					return nil, err
+				}
+				langs = append(langs, lang)
+			}
+			return langs, nil
+		}
+		return data, nil
+	}
+}
+
+func stringToClientAuthHookFunc() mapstructure.DecodeHookFuncType {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data any,
+	) (any, error) {
+		if f.Kind() != reflect.String || t != reflect.TypeOf(NoClientAuth) {
+			return data, nil
+		}
+
+		return ParseClientAuth(data.(string))
+	}
+}
+
+func stringToConnectionModeHookFunc() mapstructure.DecodeHookFuncType {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data any,
+	) (any, error) {
+		if f.Kind() != reflect.String || t != reflect.TypeOf(redgiant.PersistentConnectionMode) {
+			return data, nil
+		}
+
+		return redgiant.ParseConnectionMode(data.(string))
+	}
+}
+
+func stringToErrorFormatHookFunc() mapstructure.DecodeHookFuncType {
+	return func(
+		f reflect.Type,
+		t reflect.Type,
+		data any,
+	) (any, error) {
+		if f.Kind() != reflect.String || t != reflect.TypeOf(rgerrors.ProblemFormat) {
+			return data, nil
+		}
+
+		return rgerrors.ParseFormat(data.(string))
+	}
+}
@@ -0,0 +1,101 @@
+package errors
+
+import (
+	stderrors "errors"
+	"io"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// Fault tells who is responsible for an error.
+type Fault uint8
+
+const (
+	UnknownFault Fault = iota
+	// ClientFault is caused by the request, e.g. an unknown device.
+	ClientFault
+	// InverterFault is caused by the inverter or the connection to it.
+	InverterFault
+)
+
+func (f Fault) String() string {
+	switch f {
+	case UnknownFault:
+		return "unknown"
+	case ClientFault:
+		return "client"
+	case InverterFault:
+		return "inverter"
+	}
+	return strconv.Itoa(int(f))
+}
+
+func WithFault(f Fault) OptFunc {
+	return func(o *options) {
+		o.fault = f
+	}
+}
+
+// WithTransient marks the error as transient, i.e. retrying may succeed.
+func WithTransient(transient bool) OptFunc {
+	return func(o *options) {
+		o.transient = transient
+	}
+}
+
+// Is, As and Unwrap are the ones of the standard library, so importers of this
+// package do not have to import both.
+var (
+	Is     = stderrors.Is
+	As     = stderrors.As
+	Unwrap = stderrors.Unwrap
+)
+
+// HasCode reports whether any RedgiantError in the chain of err has the code.
+func HasCode(err error, code Code) bool {
+	for err != nil {
+		var rge *RedgiantError
+		if !As(err, &rge) {
+			return false
+		}
+		if rge.code == code {
+			return true
+		}
+		err = rge.cause
+	}
+	return false
+}
+
+// IsTransient reports whether retrying may succeed. Errors that were not
+// classified are transient if they are timeouts or connection failures.
+func IsTransient(err error) bool {
+	var rge *RedgiantError
+	if As(err, &rge) && (rge.transient || rge.fault != UnknownFault) {
+		return rge.transient
+	}
+
+	var ne net.Error
+	if As(err, &ne) && ne.Timeout() {
+		return true
+	}
+	return Is(err, syscall.ECONNREFUSED) ||
+		Is(err, syscall.ECONNRESET) ||
+		Is(err, io.ErrUnexpectedEOF)
+}
+
+// FaultOf returns the fault of the outermost classified RedgiantError in the
+// chain of err.
+func FaultOf(err error) Fault {
+	for err != nil {
+		var rge *RedgiantError
+		if !As(err, &rge) {
+			return UnknownFault
+		}
+		if rge.fault != UnknownFault {
+			return rge.fault
+		}
+		err = rge.cause
+	}
+	return UnknownFault
+}
@@ -1,9 +1,8 @@
 package errors
 
 import (
-	"net/http"
-
 	"maps"
+	"net/http"
 
 	"github.com/labstack/echo/v4"
 	"github.com/pkg/errors"
@@ -15,6 +14,8 @@ type RedgiantErrorer interface {
 	error
 	zerolog.LogObjectMarshaler
 	SendAsResponse(c echo.Context)
+	SendAsProblem(c echo.Context)
+	Send(c echo.Context, f Format)
 }
 
 type HTTPDetail uint8
@@ -29,77 +30,168 @@ type Context map[string]any
 
 type options struct {
 	context      Context
+	code         Code
+	fault        Fault
+	transient    bool
 	httpCode     int
 	httpDetail   HTTPDetail
+	httpHeader   http.Header
 	hiddenFrames uint
 }
 
-type optFunc = func(*options)
+type OptFunc = func(*options)
 
-func WithContext(ctx Context) optFunc {
+// WithContext adds to the context. Keys that are already set are overwritten.
+func WithContext(ctx Context) OptFunc {
 	return func(o *options) {
-		o.context = ctx
+		if o.context == nil {
+			o.context = Context{}
+		}
+		maps.Copy(o.context, ctx)
 	}
 }
 
-func WithHTTPCode(code int) optFunc {
+func WithCode(code Code) OptFunc {
+	return func(o *options) {
+		o.code = code
+	}
+}
+
+func WithHTTPCode(code int) OptFunc {
 	return func(o *options) {
 		o.httpCode = code
 	}
 }
 
-func WithHTTPDetail(detail HTTPDetail) optFunc {
+func WithHTTPDetail(detail HTTPDetail) OptFunc {
 	return func(o *options) {
 		o.httpDetail = detail
 	}
 }
 
-func WithHiddenFrames(n uint) optFunc {
+func WithHTTPHeader(key string, value string) OptFunc {
+	return func(o *options) {
+		if o.httpHeader == nil {
+			o.httpHeader = http.Header{}
+		}
+		o.httpHeader.Set(key, value)
+	}
+}
+
+func WithHiddenFrames(n uint) OptFunc {
 	return func(o *options) {
 		o.hiddenFrames = n
 	}
 }
 
 type RedgiantError struct {
+	// err carries the message and the stack trace.
 	err          error
+	cause        error
 	context      map[string]any
+	code         Code
+	fault        Fault
+	transient    bool
 	httpCode     int
 	httpDetail   HTTPDetail
+	httpHeader   http.Header
 	hiddenFrames uint
 }
 
-func New(msg string, opts ...optFunc) *RedgiantError {
-	o := options{
-		httpCode:     http.StatusInternalServerError,
+func newOptions() options {
+	return options{
 		httpDetail:   MessageHTTPDetail,
 		hiddenFrames: 1,
 	}
+}
+
+func newRedgiantError(err error, cause error, o options, opts []OptFunc) *RedgiantError {
 	for _, fn := range opts {
 		fn(&o)
 	}
 	return &RedgiantError{
-		err:          errors.New(msg),
+		err:          err,
+		cause:        cause,
 		context:      o.context,
+		code:         o.code,
+		fault:        o.fault,
+		transient:    o.transient,
 		httpCode:     o.httpCode,
 		httpDetail:   o.httpDetail,
+		httpHeader:   o.httpHeader,
 		hiddenFrames: o.hiddenFrames,
 	}
 }
 
-func Wrap(err error, opts ...optFunc) error {
-	if _, ok := err.(*RedgiantError); ok || err == nil {
+func New(msg string, opts ...OptFunc) *RedgiantError {
+	return newRedgiantError(errors.New(msg), nil, newOptions(), opts)
+}
+
+// Wrap annotates err, which is kept as cause for Is and As. The code, context,
+// classification and HTTP properties of a RedgiantError in the chain of err
+// are inherited. The options take precedence and contexts are merged.
+func Wrap(err error, opts ...OptFunc) error {
+	if err == nil {
 		return nil
 	}
 
-	return New(err.Error(), append([]optFunc{WithHiddenFrames(2)}, opts...)...)
+	o := newOptions()
+	var rge *RedgiantError
+	if errors.As(err, &rge) {
+		o.context = maps.Clone(rge.context)
+		o.code = rge.code
+		o.fault = rge.fault
+		o.transient = rge.transient
+		o.httpCode = rge.httpCode
+		o.httpDetail = rge.httpDetail
+		o.httpHeader = rge.httpHeader.Clone()
+	}
+	return newRedgiantError(errors.New(err.Error()), err, o, opts)
 }
 
 func (rge RedgiantError) Error() string {
 	return rge.err.Error()
 }
 
+// Unwrap returns the wrapped error, if any.
+func (rge RedgiantError) Unwrap() error {
+	return rge.cause
+}
+
+func (rge RedgiantError) Code() Code {
+	return rge.code
+}
+
+func (rge RedgiantError) Fault() Fault {
+	return rge.fault
+}
+
+func (rge RedgiantError) Transient() bool {
+	return rge.transient
+}
+
+// HTTPCode returns the status code of the response. Without an explicit code,
+// it is derived from the classification.
+func (rge RedgiantError) HTTPCode() int {
+	if rge.httpCode != 0 {
+		return rge.httpCode
+	}
+	switch {
+	case rge.fault == ClientFault:
+		return http.StatusBadRequest
+	case rge.fault == InverterFault && rge.transient:
+		return http.StatusServiceUnavailable
+	case rge.fault == InverterFault:
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
 func (rge RedgiantError) MarshalZerologObject(e *zerolog.Event) {
 	e.Str(zerolog.MessageFieldName, rge.Error())
+	if rge.code != NoCode {
+		e.Str("code", string(rge.code))
+	}
 
 	s := pkgerrors.MarshalStack(rge.err).([]map[string]string)
 	// drop the frames that show the construction of the RedgiantError
@@ -108,17 +200,29 @@ func (rge RedgiantError) MarshalZerologObject(e *zerolog.Event) {
 	for k, v := range rge.context {
 		e.Any(k, v)
 	}
+	if rge.cause != nil {
+		e.Str("cause", rge.cause.Error())
+	}
 }
 
 func (rge RedgiantError) SendAsResponse(c echo.Context) {
 	var m string
 	if rge.httpDetail == NoHTTPDetail {
-		m = http.StatusText(rge.httpCode)
+		m = http.StatusText(rge.HTTPCode())
 	} else {
 		m = rge.Error()
 	}
 	e := map[string]any{zerolog.MessageFieldName: m}
 	maps.Copy(e, rge.context)
 	i := map[string]any{zerolog.ErrorFieldName: e}
-	c.JSON(rge.httpCode, &i)
+	rge.setHeaders(c)
+	c.JSON(rge.HTTPCode(), &i)
+}
+
+func (rge RedgiantError) setHeaders(c echo.Context) {
+	for k, vs := range rge.httpHeader {
+		for _, v := range vs {
+			c.Response().Header().Add(k, v)
+		}
+	}
 }
@@ -0,0 +1,71 @@
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrap(t *testing.T) {
+	assert.Nil(t, Wrap(nil))
+
+	inner := New(
+		"unknown device",
+		WithCode(UnknownDeviceCode),
+		WithFault(ClientFault),
+		WithHTTPCode(http.StatusUnprocessableEntity),
+		WithContext(Context{"deviceID": 3, "language": "en_us"}),
+	)
+	err := Wrap(fmt.Errorf("reading: %w", inner), WithContext(Context{"language": "de_de", "service": "real"}))
+	require.Error(t, err)
+
+	var rge *RedgiantError
+	require.True(t, As(err, &rge))
+	assert.NotSame(t, inner, rge, "the outermost error has to be found first")
+	assert.Equal(t, UnknownDeviceCode, rge.Code())
+	assert.Equal(t, ClientFault, rge.Fault())
+	assert.Equal(t, http.StatusUnprocessableEntity, rge.HTTPCode())
+	assert.Equal(t, map[string]any{"deviceID": 3, "language": "de_de", "service": "real"}, rge.context)
+	assert.Equal(t, Context{"deviceID": 3, "language": "en_us"}, Context(inner.context), "wrapping must not change the cause")
+
+	assert.True(t, Is(err, inner))
+	assert.True(t, HasCode(err, UnknownDeviceCode))
+	assert.False(t, HasCode(err, UnknownLanguageCode))
+}
+
+func TestClassification(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		err       error
+		fault     Fault
+		transient bool
+		httpCode  int
+	}{
+		{"unclassified", New("boom"), UnknownFault, false, http.StatusInternalServerError},
+		{"client", New("bad", WithFault(ClientFault)), ClientFault, false, http.StatusBadRequest},
+		{"inverter", New("bad", WithFault(InverterFault)), InverterFault, false, http.StatusBadGateway},
+		{"inverter transient", New("gone", WithFault(InverterFault), WithTransient(true)), InverterFault, true, http.StatusServiceUnavailable},
+		{"explicit HTTP code", New("bad", WithFault(ClientFault), WithHTTPCode(http.StatusNotFound)), ClientFault, false, http.StatusNotFound},
+		{"connection refused", Wrap(fmt.Errorf("dial: %w", syscall.ECONNREFUSED)), UnknownFault, true, http.StatusInternalServerError},
+		{"unexpected EOF", Wrap(io.ErrUnexpectedEOF), UnknownFault, true, http.StatusInternalServerError},
+		{"inherited", Wrap(New("gone", WithFault(InverterFault), WithTransient(true))), InverterFault, true, http.StatusServiceUnavailable},
+		{"overridden", Wrap(io.ErrUnexpectedEOF, WithFault(InverterFault), WithTransient(false)), InverterFault, false, http.StatusBadGateway},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.fault, FaultOf(tc.err))
+			assert.Equal(t, tc.transient, IsTransient(tc.err))
+
+			var rge *RedgiantError
+			require.True(t, As(tc.err, &rge))
+			assert.Equal(t, tc.httpCode, rge.HTTPCode())
+		})
+	}
+
+	assert.False(t, IsTransient(stderrors.New("boom")))
+}
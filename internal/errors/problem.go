@@ -0,0 +1,133 @@
+package errors
+
+import (
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// Code identifies the kind of an error in responses, so clients do not have to
+// match messages. Codes are stable and documented in openapi.yaml.
+type Code string
+
+const (
+	NoCode                   Code = ""
+	UnknownDeviceCode        Code = "unknown-device"
+	UnknownDeviceTypeCode    Code = "unknown-device-type"
+	UnknownLanguageCode      Code = "unknown-language"
+	UnknownI18NCodeCode      Code = "unknown-i18n-code"
+	UnknownNameCode          Code = "unknown-name"
+	UnknownMetricCode        Code = "unknown-metric"
+	UnsupportedDeviceCode    Code = "unsupported-device"
+	MissingMeasurementCode   Code = "missing-measurement"
+	InverterDisconnectedCode Code = "inverter-disconnected"
+	// InverterErrorCode is used for result codes of the inverter, which are
+	// given as "resultCode".
+	InverterErrorCode     Code = "inverter-error"
+	InvalidParameterCode  Code = "invalid-parameter"
+	UnauthorizedCode      Code = "unauthorized"
+	InsufficientScopeCode Code = "insufficient-scope"
+	NotImplementedCode    Code = "not-implemented"
+)
+
+var codeTitles = map[Code]string{
+	UnknownDeviceCode:        "Unknown device",
+	UnknownDeviceTypeCode:    "Unknown device type",
+	UnknownLanguageCode:      "Unknown language",
+	UnknownI18NCodeCode:      "Unknown i18n code",
+	UnknownNameCode:          "Unknown name",
+	UnknownMetricCode:        "Unknown metric",
+	UnsupportedDeviceCode:    "Unsupported device",
+	MissingMeasurementCode:   "Missing measurement",
+	InverterDisconnectedCode: "Inverter disconnected",
+	InverterErrorCode:        "Inverter error",
+	InvalidParameterCode:     "Invalid parameter",
+	UnauthorizedCode:         "Unauthorized",
+	InsufficientScopeCode:    "Insufficient scope",
+	NotImplementedCode:       "Not implemented",
+}
+
+// Type returns the URI identifying the problem type of the code as required by
+// RFC 7807. Errors without a code use "about:blank".
+func (c Code) Type() string {
+	if c == NoCode {
+		return "about:blank"
+	}
+	return "urn:redgiant:problem:" + string(c)
+}
+
+// Format selects how errors are sent in responses.
+type Format uint8
+
+const (
+	// ProblemFormat sends RFC 7807 problem details.
+	ProblemFormat Format = iota
+	// LegacyFormat sends {"error": {"message": ..., <context>}} as servers
+	// before the problem details did.
+	LegacyFormat
+)
+
+func (f Format) String() string {
+	switch f {
+	case ProblemFormat:
+		return "problem"
+	case LegacyFormat:
+		return "legacy"
+	}
+	return strconv.Itoa(int(f))
+}
+
+func ParseFormat(formatStr string) (Format, error) {
+	for _, f := range []Format{
+		ProblemFormat,
+		LegacyFormat,
+	} {
+		if strings.EqualFold(formatStr, f.String()) {
+			return f, nil
+		}
+	}
+	return ProblemFormat, New(
+		"unknown error format",
+		WithContext(Context{"format": formatStr}),
+	)
+}
+
+const ProblemContentType = "application/problem+json"
+
+// SendAsProblem sends the error as RFC 7807 problem details. The context is
+// added as extension members if the HTTP detail allows it.
+func (rge RedgiantError) SendAsProblem(c echo.Context) {
+	p := map[string]any{}
+	if rge.httpDetail == ContextHTTPDetail {
+		maps.Copy(p, rge.context)
+	}
+	p["type"] = rge.code.Type()
+	p["status"] = rge.HTTPCode()
+	if title, ok := codeTitles[rge.code]; ok {
+		p["title"] = title
+	} else {
+		p["title"] = http.StatusText(rge.HTTPCode())
+	}
+	if rge.code != NoCode {
+		p["code"] = rge.code
+	}
+	if rge.httpDetail != NoHTTPDetail {
+		p["detail"] = rge.Error()
+	}
+
+	rge.setHeaders(c)
+	c.Response().Header().Set(echo.HeaderContentType, ProblemContentType)
+	c.JSON(rge.HTTPCode(), p)
+}
+
+// Send sends the error in the format.
+func (rge RedgiantError) Send(c echo.Context, f Format) {
+	if f == LegacyFormat {
+		rge.SendAsResponse(c)
+	} else {
+		rge.SendAsProblem(c)
+	}
+}
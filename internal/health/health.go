@@ -1,43 +1,194 @@
 package health
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/certs"
 	"github.com/pmeier/redgiant/internal/config"
 )
 
-func HealthRouteFunc() (string, string, echo.HandlerFunc) {
+const (
+	OKStatus       = "ok"
+	DegradedStatus = "degraded"
+	FailStatus     = "fail"
+	SkippedStatus  = "skipped"
+)
+
+type Status struct {
+	// Status is "ok" if the inverter is connected and "degraded" otherwise. The
+	// server is able to answer requests in both cases, so this does not affect
+	// the status code.
+	Status   string                   `json:"status"`
+	Inverter redgiant.ConnectionState `json:"inverter"`
+}
+
+func HealthRouteFunc(stateFunc func() redgiant.ConnectionState) (string, string, echo.HandlerFunc) {
 	return http.MethodGet, "/health", func(c echo.Context) error {
-		return c.NoContent(http.StatusOK)
+		s := Status{Status: OKStatus, Inverter: stateFunc()}
+		if !s.Inverter.Connected && !s.Inverter.Idle {
+			s.Status = DegradedStatus
+		}
+		return c.JSON(http.StatusOK, s)
+	}
+}
+
+func LivenessRouteFunc() (string, string, echo.HandlerFunc) {
+	return http.MethodGet, "/health/live", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, map[string]string{"status": OKStatus})
+	}
+}
+
+type Check struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	// Age is the time since the checked event in seconds.
+	Age *float64 `json:"age,omitempty"`
+}
+
+type Readiness struct {
+	Status string           `json:"status"`
+	Checks map[string]Check `json:"checks"`
+}
+
+type ReadinessOptions struct {
+	// MaxHeartbeatAge and MaxReadAge are the maximum times since the last
+	// heartbeat and data read. Zero disables the check.
+	MaxHeartbeatAge time.Duration
+	MaxReadAge      time.Duration
+	// Polling reports whether data is read periodically. If not, the read
+	// check is skipped. nil is treated as always polling.
+	Polling func() bool
+}
+
+func ageCheck(name string, t time.Time, maxAge time.Duration, now time.Time) Check {
+	if maxAge == 0 {
+		return Check{Status: SkippedStatus}
+	}
+	if t.IsZero() {
+		return Check{Status: FailStatus, Detail: fmt.Sprintf("no %s yet", name)}
 	}
+
+	age := now.Sub(t).Seconds()
+	c := Check{Status: OKStatus, Age: &age}
+	if now.Sub(t) > maxAge {
+		c.Status = FailStatus
+		c.Detail = fmt.Sprintf("last %s older than %s", name, maxAge)
+	}
+	return c
 }
 
-func IsHealthy(host string, port uint) bool {
-	r, err := http.Get(
-		(&url.URL{
-			Scheme: "http",
-			Host:   fmt.Sprintf("%s:%d", host, port),
-			Path:   "/health"}).String())
-	if err == nil && r.StatusCode == http.StatusOK {
-		return true
-	} else {
-		return false
+func NewReadiness(s redgiant.ConnectionState, opts ReadinessOptions, now time.Time) Readiness {
+	connection := Check{Status: OKStatus}
+	if !s.Connected {
+		connection = Check{Status: FailStatus, Detail: s.LastError}
+	}
+	heartbeat := ageCheck("heartbeat", s.LastHeartbeat, opts.MaxHeartbeatAge, now)
+	// a connection closed on purpose will be re-established on the next request
+	if s.Idle {
+		connection = Check{Status: SkippedStatus, Detail: "idle"}
+		heartbeat = Check{Status: SkippedStatus, Detail: "idle"}
+	}
+
+	read := ageCheck("read", s.LastRead, opts.MaxReadAge, now)
+	if opts.Polling != nil && !opts.Polling() {
+		read = Check{Status: SkippedStatus, Detail: "not polling"}
+	}
+
+	r := Readiness{
+		Status: OKStatus,
+		Checks: map[string]Check{
+			"connection": connection,
+			"heartbeat":  heartbeat,
+			"read":       read,
+		},
 	}
+	for _, c := range r.Checks {
+		if c.Status == FailStatus {
+			r.Status = FailStatus
+		}
+	}
+	return r
 }
 
-func WaitForHealthy(host string, port uint, d time.Duration) error {
+func ReadinessRouteFunc(stateFunc func() redgiant.ConnectionState, opts ReadinessOptions) (string, string, echo.HandlerFunc) {
+	return http.MethodGet, "/health/ready", func(c echo.Context) error {
+		r := NewReadiness(stateFunc(), opts, time.Now())
+		code := http.StatusOK
+		if r.Status != OKStatus {
+			code = http.StatusServiceUnavailable
+		}
+		return c.JSON(code, r)
+	}
+}
+
+// BaseURL returns the URL the server configured by c can be reached at. Since
+// unspecified addresses cannot be dialed on every platform, they are replaced
+// by the loopback address.
+func BaseURL(c config.ServerConfig) string {
+	scheme := "http"
+	if c.TLS.Enabled {
+		scheme = "https"
+	}
+
+	host := c.Host
+	if ip := net.ParseIP(host); ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+
+	return (&url.URL{Scheme: scheme, Host: net.JoinHostPort(host, fmt.Sprint(c.Port))}).String()
+}
+
+func NewHTTPClient(c config.ServerConfig) (*http.Client, error) {
+	tc, err := certs.ClientTLSConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: &http.Transport{TLSClientConfig: tc},
+		Timeout:   5 * time.Second,
+	}, nil
+}
+
+// probe requests the endpoint and returns the response body. The error is
+// non-nil if the request failed or the response status is not 200.
+func probe(c *http.Client, u string) ([]byte, error) {
+	r, err := c.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	b, _ := io.ReadAll(r.Body)
+	if r.StatusCode != http.StatusOK {
+		return b, errors.New(r.Status)
+	}
+	return b, nil
+}
+
+func IsHealthy(c *http.Client, baseURL string) bool {
+	_, err := probe(c, baseURL+"/health")
+	return err == nil
+}
+
+func WaitForHealthy(c *http.Client, baseURL string, d time.Duration) error {
 	timeout := time.After(d)
 	for {
 		select {
 		case <-timeout:
 			return errors.New("server failed to start")
 		default:
-			if IsHealthy(host, port) {
+			if IsHealthy(c, baseURL) {
 				return nil
 			} else {
 				<-time.After(time.Second)
@@ -46,10 +197,33 @@ func WaitForHealthy(host string, port uint, d time.Duration) error {
 	}
 }
 
-func Run(c config.Config) error {
-	if IsHealthy(c.Server.Host, c.Server.Port) {
-		return nil
-	} else {
-		return errors.New("server not healthy")
+type RunOptions struct {
+	// Ready checks readiness rather than liveness.
+	Ready bool
+	// JSON prints the response of the server.
+	JSON bool
+}
+
+func Run(c config.Config, opts RunOptions) error {
+	hc, err := NewHTTPClient(c.Server)
+	if err != nil {
+		return err
+	}
+
+	path, msg := "/health/live", "server not healthy"
+	if opts.Ready {
+		path, msg = "/health/ready", "server not ready"
+	}
+
+	b, err := probe(hc, BaseURL(c.Server)+path)
+	if opts.JSON {
+		if err != nil && !json.Valid(b) {
+			b, _ = json.Marshal(map[string]string{"status": FailStatus, "error": err.Error()})
+		}
+		os.Stdout.Write(append(bytes.TrimSpace(b), '\n'))
+	}
+	if err != nil {
+		return errors.New(msg)
 	}
+	return nil
 }
@@ -0,0 +1,170 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/certs"
+	"github.com/pmeier/redgiant/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsHealthyTLS(t *testing.T) {
+	dir := t.TempDir()
+	c := config.ServerConfig{Host: "127.0.0.1", TLS: config.TLSConfig{
+		Enabled:    true,
+		CertFile:   filepath.Join(dir, "cert.pem"),
+		KeyFile:    filepath.Join(dir, "key.pem"),
+		SelfSigned: true,
+	}}
+	tc, err := certs.ServerTLSConfig(c)
+	require.NoError(t, err)
+
+	e := echo.New()
+	method, path, handler := HealthRouteFunc(func() redgiant.ConnectionState { return redgiant.ConnectionState{Connected: true} })
+	e.Add(method, path, handler)
+	srv := httptest.NewUnstartedServer(e)
+	srv.TLS = tc
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	hc, err := NewHTTPClient(c)
+	require.NoError(t, err)
+	assert.True(t, IsHealthy(hc, srv.URL))
+
+	// the self-signed certificate is not trusted without the CA
+	assert.False(t, IsHealthy(&http.Client{}, srv.URL))
+}
+
+func TestNewReadiness(t *testing.T) {
+	now := time.Now()
+	opts := ReadinessOptions{MaxHeartbeatAge: 30 * time.Second, MaxReadAge: time.Minute}
+	connected := redgiant.ConnectionState{Connected: true, LastHeartbeat: now.Add(-time.Second), LastRead: now.Add(-10 * time.Second)}
+
+	tests := []struct {
+		name           string
+		state          func(s *redgiant.ConnectionState)
+		opts           func(o *ReadinessOptions)
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{
+			name:           "connected",
+			expectedStatus: OKStatus,
+			expectedChecks: map[string]string{"connection": OKStatus, "heartbeat": OKStatus, "read": OKStatus},
+		},
+		{
+			name:           "disconnected",
+			state:          func(s *redgiant.ConnectionState) { s.Connected = false; s.LastError = "connection refused" },
+			expectedStatus: FailStatus,
+			expectedChecks: map[string]string{"connection": FailStatus, "heartbeat": OKStatus, "read": OKStatus},
+		},
+		{
+			name: "idle",
+			state: func(s *redgiant.ConnectionState) {
+				s.Connected = false
+				s.Idle = true
+				s.LastHeartbeat = now.Add(-time.Hour)
+			},
+			expectedStatus: OKStatus,
+			expectedChecks: map[string]string{"connection": SkippedStatus, "heartbeat": SkippedStatus, "read": OKStatus},
+		},
+		{
+			name:           "stale heartbeat",
+			state:          func(s *redgiant.ConnectionState) { s.LastHeartbeat = now.Add(-time.Minute) },
+			expectedStatus: FailStatus,
+			expectedChecks: map[string]string{"connection": OKStatus, "heartbeat": FailStatus, "read": OKStatus},
+		},
+		{
+			name:           "no heartbeat yet",
+			state:          func(s *redgiant.ConnectionState) { s.LastHeartbeat = time.Time{} },
+			expectedStatus: FailStatus,
+			expectedChecks: map[string]string{"connection": OKStatus, "heartbeat": FailStatus, "read": OKStatus},
+		},
+		{
+			name:           "stale read",
+			state:          func(s *redgiant.ConnectionState) { s.LastRead = now.Add(-time.Hour) },
+			expectedStatus: FailStatus,
+			expectedChecks: map[string]string{"connection": OKStatus, "heartbeat": OKStatus, "read": FailStatus},
+		},
+		{
+			name:           "stale read while not polling",
+			state:          func(s *redgiant.ConnectionState) { s.LastRead = now.Add(-time.Hour) },
+			opts:           func(o *ReadinessOptions) { o.Polling = func() bool { return false } },
+			expectedStatus: OKStatus,
+			expectedChecks: map[string]string{"connection": OKStatus, "heartbeat": OKStatus, "read": SkippedStatus},
+		},
+		{
+			name:           "checks disabled",
+			state:          func(s *redgiant.ConnectionState) { s.LastHeartbeat = time.Time{}; s.LastRead = time.Time{} },
+			opts:           func(o *ReadinessOptions) { o.MaxHeartbeatAge = 0; o.MaxReadAge = 0 },
+			expectedStatus: OKStatus,
+			expectedChecks: map[string]string{"connection": OKStatus, "heartbeat": SkippedStatus, "read": SkippedStatus},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, o := connected, opts
+			if test.state != nil {
+				test.state(&s)
+			}
+			if test.opts != nil {
+				test.opts(&o)
+			}
+
+			r := NewReadiness(s, o, now)
+			assert.Equal(t, test.expectedStatus, r.Status)
+			checks := make(map[string]string, len(r.Checks))
+			for name, c := range r.Checks {
+				checks[name] = c.Status
+			}
+			assert.Equal(t, test.expectedChecks, checks)
+		})
+	}
+}
+
+func TestHealthRoutes(t *testing.T) {
+	var state redgiant.ConnectionState
+	stateFunc := func() redgiant.ConnectionState { return state }
+
+	e := echo.New()
+	for _, routeFunc := range []func() (string, string, echo.HandlerFunc){
+		LivenessRouteFunc,
+		func() (string, string, echo.HandlerFunc) { return ReadinessRouteFunc(stateFunc, ReadinessOptions{}) },
+	} {
+		method, path, handler := routeFunc()
+		e.Add(method, path, handler)
+	}
+
+	get := func(path string) (int, string) {
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body struct {
+			Status string `json:"status"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body.Status
+	}
+
+	// the server is alive regardless of the inverter
+	code, status := get("/health/live")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, OKStatus, status)
+
+	code, status = get("/health/ready")
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, FailStatus, status)
+
+	state.Connected = true
+	code, status = get("/health/ready")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, OKStatus, status)
+}
@@ -0,0 +1,207 @@
+package history
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/pmeier/redgiant/internal/errors"
+)
+
+type Query struct {
+	DeviceID int
+	// Metrics selects the series to return. If empty, all series of the device are returned.
+	Metrics []string
+	From    time.Time
+	To      time.Time
+	// Step is the width of the returned buckets. If zero, it is chosen to return at most maxPoints points.
+	Step time.Duration
+}
+
+type Point struct {
+	Time  time.Time `json:"time"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Mean  float64   `json:"mean"`
+	Count uint32    `json:"count"`
+}
+
+type Series struct {
+	DeviceID int     `json:"deviceID"`
+	Metric   string  `json:"metric"`
+	Unit     string  `json:"unit"`
+	Tier     string  `json:"tier"`
+	Step     string  `json:"step"`
+	Points   []Point `json:"points"`
+}
+
+const maxPoints = 1000
+
+// selectTier returns the coarsest tier that still resolves the step and keeps
+// data back to from. If the step is finer than any such tier, the finest one
+// is used. If no tier reaches back far enough, the one with the longest
+// retention is used.
+func selectTier(tiers []Tier, from time.Time, step time.Duration, now time.Time) Tier {
+	var covering []Tier
+	for _, t := range tiers {
+		if t.covers(from, now) {
+			covering = append(covering, t)
+		}
+	}
+	if len(covering) == 0 {
+		return slices.MaxFunc(tiers, func(a, b Tier) int { return int(a.Retention - b.Retention) })
+	}
+
+	slices.SortFunc(covering, func(a, b Tier) int { return int(a.Resolution - b.Resolution) })
+	best := covering[0]
+	for _, t := range covering[1:] {
+		if t.Resolution <= step {
+			best = t
+		}
+	}
+	return best
+}
+
+func (s *Store) Query(q Query) ([]Series, error) {
+	s.log.Trace().Int("deviceID", q.DeviceID).Strs("metrics", q.Metrics).Msg("Store.Query()")
+
+	if !q.From.Before(q.To) {
+		return nil, errors.New(
+			"invalid time range",
+			errors.WithCode(errors.InvalidParameterCode),
+			errors.WithContext(errors.Context{"from": q.From, "to": q.To}),
+			errors.WithHTTPCode(http.StatusUnprocessableEntity),
+			errors.WithFault(errors.ClientFault),
+			errors.WithHTTPDetail(errors.ContextHTTPDetail),
+		)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	infos, err := s.selectSeries(q.DeviceID, q.Metrics)
+	if err != nil {
+		return nil, err
+	}
+
+	step := q.Step
+	if step == 0 {
+		step = q.To.Sub(q.From) / maxPoints
+	}
+	tier := selectTier(s.tiers, q.From, step, time.Now())
+	step = max(step, tier.Resolution, time.Second)
+
+	records, err := s.readRecords(tier, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+	if i := slices.Index(s.tiers, tier); !tier.IsRaw() && i >= 0 {
+		for _, r := range s.buckets[i] {
+			records = append(records, *r)
+		}
+	}
+
+	buckets := make(map[uint32]map[int64]*record, len(infos))
+	for _, info := range infos {
+		buckets[info.ID] = map[int64]*record{}
+	}
+	from, to := q.From.UnixMilli(), q.To.UnixMilli()
+	for _, r := range records {
+		bs, ok := buckets[r.Series]
+		if !ok || r.Time < from || r.Time >= to {
+			continue
+		}
+		start := time.UnixMilli(r.Time).Truncate(step).UnixMilli()
+		b, ok := bs[start]
+		if !ok {
+			b = &record{}
+		}
+		r.Time = start
+		b.merge(r)
+		bs[start] = b
+	}
+
+	series := make([]Series, 0, len(infos))
+	for _, info := range infos {
+		ps := make([]Point, 0, len(buckets[info.ID]))
+		for _, b := range buckets[info.ID] {
+			ps = append(ps, Point{
+				Time:  time.UnixMilli(b.Time).UTC(),
+				Min:   b.Min,
+				Max:   b.Max,
+				Mean:  b.Sum / float64(b.Count),
+				Count: b.Count,
+			})
+		}
+		slices.SortFunc(ps, func(a, b Point) int { return a.Time.Compare(b.Time) })
+		series = append(series, Series{
+			DeviceID: info.DeviceID,
+			Metric:   info.Metric,
+			Unit:     info.Unit,
+			Tier:     tier.Name,
+			Step:     step.String(),
+			Points:   ps,
+		})
+	}
+	return series, nil
+}
+
+// selectSeries must be called with s.mu held.
+func (s *Store) selectSeries(deviceID int, metrics []string) ([]*seriesInfo, error) {
+	var infos []*seriesInfo
+	if len(metrics) == 0 {
+		for k, info := range s.series {
+			if k.DeviceID == deviceID {
+				infos = append(infos, info)
+			}
+		}
+		slices.SortFunc(infos, func(a, b *seriesInfo) int { return int(a.ID) - int(b.ID) })
+		return infos, nil
+	}
+
+	for _, m := range metrics {
+		info, ok := s.series[seriesKey{DeviceID: deviceID, Metric: m}]
+		if !ok {
+			return nil, errors.New(
+				"unknown metric",
+				errors.WithCode(errors.UnknownMetricCode),
+				errors.WithContext(errors.Context{"deviceID": deviceID, "metric": m}),
+				errors.WithHTTPCode(http.StatusUnprocessableEntity),
+				errors.WithFault(errors.ClientFault),
+				errors.WithHTTPDetail(errors.ContextHTTPDetail),
+			)
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// readRecords reads all records of the partitions overlapping [from, to).
+// Must be called with s.mu held.
+func (s *Store) readRecords(tier Tier, from time.Time, to time.Time) ([]record, error) {
+	entries, err := os.ReadDir(filepath.Join(s.dir, tier.Name))
+	if err != nil {
+		return nil, err
+	}
+
+	size := tier.recordSize()
+	var records []record
+	for _, e := range entries {
+		start, err := time.Parse(tier.partition, trimExt(e.Name()))
+		if err != nil || !start.Before(to) || !tier.partitionEnd(start).After(from) {
+			continue
+		}
+
+		b, err := os.ReadFile(filepath.Join(s.dir, tier.Name, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		// a crash might have left a partially written record at the end
+		for i := 0; i+size <= len(b); i += size {
+			records = append(records, decodeRecord(b[i:i+size], tier.IsRaw()))
+		}
+	}
+	return records, nil
+}
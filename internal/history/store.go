@@ -0,0 +1,294 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/poll"
+	"github.com/rs/zerolog"
+)
+
+type seriesInfo struct {
+	ID       uint32 `json:"id"`
+	DeviceID int    `json:"deviceID"`
+	Metric   string `json:"metric"`
+	Unit     string `json:"unit"`
+}
+
+type seriesKey struct {
+	DeviceID int
+	Metric   string
+}
+
+// Store is an append-only time-series store on disk. Every tier is kept in
+// its own directory with one file per partition, e.g. raw/2025-01-31.dat, so
+// retention is enforced by deleting whole files. Aggregated tiers are
+// accumulated in memory and written once a bucket is complete.
+type Store struct {
+	dir   string
+	tiers []Tier
+	log   zerolog.Logger
+
+	mu            sync.Mutex
+	series        map[seriesKey]*seriesInfo
+	nextID        uint32
+	files         map[string]*os.File
+	buckets       []map[uint32]*record
+	lastRetention time.Time
+}
+
+var _ poll.Sink = &Store{}
+
+func Open(dir string, tiers []Tier, logger zerolog.Logger) (*Store, error) {
+	for _, t := range tiers {
+		if err := os.MkdirAll(filepath.Join(dir, t.Name), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Store{
+		dir:     dir,
+		tiers:   tiers,
+		log:     logger,
+		series:  map[seriesKey]*seriesInfo{},
+		nextID:  1,
+		files:   map[string]*os.File{},
+		buckets: make([]map[uint32]*record, len(tiers)),
+	}
+	for i := range s.buckets {
+		s.buckets[i] = map[uint32]*record{}
+	}
+
+	if err := s.loadSeries(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) seriesPath() string {
+	return filepath.Join(s.dir, "series.json")
+}
+
+func (s *Store) loadSeries() error {
+	b, err := os.ReadFile(s.seriesPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var infos []*seriesInfo
+	if err := json.Unmarshal(b, &infos); err != nil {
+		return err
+	}
+	for _, info := range infos {
+		s.series[seriesKey{DeviceID: info.DeviceID, Metric: info.Metric}] = info
+		s.nextID = max(s.nextID, info.ID+1)
+	}
+	return nil
+}
+
+func (s *Store) saveSeries() error {
+	infos := make([]*seriesInfo, 0, len(s.series))
+	for _, info := range s.series {
+		infos = append(infos, info)
+	}
+	b, err := json.Marshal(infos)
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so a crash cannot corrupt the dictionary
+	tmp := s.seriesPath() + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.seriesPath())
+}
+
+func (s *Store) getSeries(deviceID int, metric string, unit string) (*seriesInfo, error) {
+	k := seriesKey{DeviceID: deviceID, Metric: metric}
+	if info, ok := s.series[k]; ok {
+		return info, nil
+	}
+
+	info := &seriesInfo{ID: s.nextID, DeviceID: deviceID, Metric: metric, Unit: unit}
+	s.series[k] = info
+	s.nextID++
+	return info, s.saveSeries()
+}
+
+type value struct {
+	deviceID int
+	metric   string
+	unit     string
+	value    float64
+}
+
+// sampleValues extracts all numeric values of a sample. Direct measurements
+// are split into <code>:voltage and <code>:current.
+func sampleValues(sample redgiant.Snapshot) []value {
+	var vs []value
+	for _, ds := range sample.Devices {
+		for _, m := range ds.Real {
+			v, err := strconv.ParseFloat(m.Value, 64)
+			if err != nil {
+				continue
+			}
+			vs = append(vs, value{deviceID: ds.Device.ID, metric: m.I18NCode, unit: m.Unit, value: v})
+		}
+		for _, m := range ds.Direct {
+			vs = append(vs,
+				value{deviceID: ds.Device.ID, metric: m.I18NCode + ":voltage", unit: m.VoltageUnit, value: float64(m.Voltage)},
+				value{deviceID: ds.Device.ID, metric: m.I18NCode + ":current", unit: m.CurrentUnit, value: float64(m.Current)},
+			)
+		}
+	}
+	return vs
+}
+
+// batch collects encoded records per partition file before they are written.
+type batch map[string][]byte
+
+func (s *Store) partitionPath(tier Tier, t time.Time) string {
+	return filepath.Join(s.dir, tier.Name, t.UTC().Format(tier.partition)+".dat")
+}
+
+func (s *Store) add(b batch, tier Tier, r record) {
+	p := s.partitionPath(tier, time.UnixMilli(r.Time))
+	b[p] = r.appendTo(b[p], tier.IsRaw())
+}
+
+func (s *Store) Write(ctx context.Context, sample redgiant.Snapshot) error {
+	s.log.Trace().Msg("Store.Write()")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t := sample.Time.UTC()
+	b := batch{}
+	for _, v := range sampleValues(sample) {
+		info, err := s.getSeries(v.deviceID, v.metric, v.unit)
+		if err != nil {
+			return err
+		}
+
+		r := record{Time: t.UnixMilli(), Series: info.ID, Min: v.value, Max: v.value, Sum: v.value, Count: 1}
+		for i, tier := range s.tiers {
+			if tier.IsRaw() {
+				s.add(b, tier, r)
+				continue
+			}
+
+			start := t.Truncate(tier.Resolution).UnixMilli()
+			bucket, ok := s.buckets[i][info.ID]
+			if ok && bucket.Time != start {
+				s.add(b, tier, *bucket)
+				ok = false
+			}
+			if !ok {
+				bucket = &record{Time: start, Series: info.ID}
+				s.buckets[i][info.ID] = bucket
+			}
+			bucket.merge(record{Time: start, Series: info.ID, Min: r.Min, Max: r.Max, Sum: r.Sum, Count: 1})
+		}
+	}
+
+	errs := []error{s.write(b)}
+	if time.Since(s.lastRetention) > time.Hour {
+		s.lastRetention = time.Now()
+		errs = append(errs, s.enforceRetention(time.Now()))
+	}
+
+	return errors.Join(errs...)
+}
+
+// write appends the batch to the partition files. The file handle of the most
+// recent partition of every tier is kept open. Must be called with s.mu held.
+func (s *Store) write(b batch) error {
+	var errs []error
+	for path, data := range b {
+		tier := filepath.Base(filepath.Dir(path))
+		f, ok := s.files[tier]
+		if ok && f.Name() != path {
+			f.Close()
+			ok = false
+		}
+		if !ok {
+			var err error
+			f, err = os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			s.files[tier] = f
+		}
+
+		if _, err := f.Write(data); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// enforceRetention deletes all partitions that lie completely outside of the
+// retention of their tier. Must be called with s.mu held.
+func (s *Store) enforceRetention(now time.Time) error {
+	var errs []error
+	for _, tier := range s.tiers {
+		if tier.Retention == 0 {
+			continue
+		}
+
+		entries, err := os.ReadDir(filepath.Join(s.dir, tier.Name))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, e := range entries {
+			start, err := time.Parse(tier.partition, trimExt(e.Name()))
+			if err != nil {
+				continue
+			}
+			if tier.partitionEnd(start).Before(now.Add(-tier.Retention)) {
+				s.log.Debug().Str("tier", tier.Name).Str("partition", e.Name()).Msg("removing expired partition")
+				errs = append(errs, os.Remove(filepath.Join(s.dir, tier.Name, e.Name())))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func trimExt(name string) string {
+	return name[:len(name)-len(filepath.Ext(name))]
+}
+
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Incomplete buckets are written as well. Should the bucket continue after
+	// a restart, queries merge both records.
+	b := batch{}
+	for i, tier := range s.tiers {
+		for _, r := range s.buckets[i] {
+			s.add(b, tier, *r)
+		}
+		clear(s.buckets[i])
+	}
+	errs := []error{s.write(b)}
+
+	for _, f := range s.files {
+		errs = append(errs, f.Close())
+	}
+	clear(s.files)
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSample(t time.Time, power string) redgiant.Snapshot {
+	return redgiant.Snapshot{
+		Time: t,
+		Devices: []redgiant.DeviceSnapshot{{
+			Device: redgiant.Device{ID: 1},
+			Real: []redgiant.RealMeasurement{
+				{I18NCode: "I18N_COMMON_TOTAL_ACTIVE_POWER", Value: power, Unit: "kW"},
+				{I18NCode: "I18N_COMMON_RUNNING_STATE", Value: "Run"},
+			},
+		}},
+	}
+}
+
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	tiers := DefaultTiers(0, 0, 0, 0)
+	ctx := context.Background()
+
+	s, err := Open(dir, tiers, zerolog.Nop())
+	require.NoError(t, err)
+
+	start := time.Now().UTC().Truncate(time.Hour).Add(-time.Hour)
+	for i, v := range []string{"1", "3", "2", "6"} {
+		require.NoError(t, s.Write(ctx, newSample(start.Add(time.Duration(i)*2*time.Minute), v)))
+	}
+	require.NoError(t, s.Close())
+
+	// reopening has to restore the series dictionary
+	s, err = Open(dir, tiers, zerolog.Nop())
+	require.NoError(t, err)
+	defer s.Close()
+
+	series, err := s.Query(Query{DeviceID: 1, From: start, To: start.Add(time.Hour), Step: 5 * time.Minute})
+	require.NoError(t, err)
+	require.Len(t, series, 1)
+	assert.Equal(t, "I18N_COMMON_TOTAL_ACTIVE_POWER", series[0].Metric)
+	assert.Equal(t, "kW", series[0].Unit)
+	assert.Equal(t, "5m", series[0].Tier)
+	require.Len(t, series[0].Points, 2)
+	assert.Equal(t, Point{Time: start, Min: 1, Max: 3, Mean: 2, Count: 3}, series[0].Points[0])
+	assert.Equal(t, Point{Time: start.Add(5 * time.Minute), Min: 6, Max: 6, Mean: 6, Count: 1}, series[0].Points[1])
+
+	series, err = s.Query(Query{DeviceID: 1, Metrics: []string{"I18N_COMMON_TOTAL_ACTIVE_POWER"}, From: start, To: start.Add(time.Hour), Step: time.Minute})
+	require.NoError(t, err)
+	assert.Equal(t, "raw", series[0].Tier)
+	assert.Len(t, series[0].Points, 4)
+
+	_, err = s.Query(Query{DeviceID: 1, Metrics: []string{"I18N_UNKNOWN"}, From: start, To: start.Add(time.Hour)})
+	assert.Error(t, err)
+}
+
+func TestStoreAutoStep(t *testing.T) {
+	s, err := Open(t.TempDir(), DefaultTiers(0, 0, 0, 0), zerolog.Nop())
+	require.NoError(t, err)
+	defer s.Close()
+
+	now := time.Now()
+	require.NoError(t, s.Write(context.Background(), newSample(now, "1")))
+
+	tests := []struct {
+		name     string
+		from     time.Time
+		expected string
+	}{
+		{name: "hour", from: now.Add(-time.Hour), expected: "raw"},
+		{name: "year", from: now.AddDate(-1, 0, 0), expected: "1h"},
+		{name: "years", from: now.AddDate(-3, 0, 0), expected: "1d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			series, err := s.Query(Query{DeviceID: 1, From: test.from, To: now.Add(time.Minute)})
+			require.NoError(t, err)
+			require.Len(t, series, 1)
+			assert.Equal(t, test.expected, series[0].Tier)
+		})
+	}
+}
+
+func TestSelectTier(t *testing.T) {
+	now := time.Now()
+	tiers := DefaultTiers(48*time.Hour, 30*24*time.Hour, 365*24*time.Hour, 0)
+
+	tests := []struct {
+		name     string
+		from     time.Time
+		step     time.Duration
+		expected string
+	}{
+		{name: "recent fine", from: now.Add(-time.Hour), step: time.Minute, expected: "raw"},
+		{name: "recent coarse", from: now.Add(-time.Hour), step: 10 * time.Minute, expected: "5m"},
+		{name: "past retention", from: now.Add(-72 * time.Hour), step: time.Minute, expected: "5m"},
+		{name: "years", from: now.AddDate(-3, 0, 0), step: time.Hour, expected: "1d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.expected, selectTier(tiers, test.from, test.step, now).Name)
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package history
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+type Tier struct {
+	Name string
+	// Resolution is the bucket width of the tier. Zero means raw samples.
+	Resolution time.Duration
+	// Retention is how long data is kept. Zero keeps data forever.
+	Retention time.Duration
+	// partition is the time layout used to name the files of the tier.
+	partition string
+}
+
+func (t Tier) IsRaw() bool {
+	return t.Resolution == 0
+}
+
+func (t Tier) covers(from time.Time, now time.Time) bool {
+	return t.Retention == 0 || !from.Before(now.Add(-t.Retention))
+}
+
+func (t Tier) recordSize() int {
+	if t.IsRaw() {
+		return rawRecordSize
+	}
+	return aggregateRecordSize
+}
+
+// partitionEnd returns the end of the time range covered by a partition.
+func (t Tier) partitionEnd(start time.Time) time.Time {
+	if t.partition == monthPartition {
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+const (
+	dayPartition   = "2006-01-02"
+	monthPartition = "2006-01"
+)
+
+func DefaultTiers(raw, fiveMinutes, hourly, daily time.Duration) []Tier {
+	return []Tier{
+		{Name: "raw", Resolution: 0, Retention: raw, partition: dayPartition},
+		{Name: "5m", Resolution: 5 * time.Minute, Retention: fiveMinutes, partition: dayPartition},
+		{Name: "1h", Resolution: time.Hour, Retention: hourly, partition: monthPartition},
+		{Name: "1d", Resolution: 24 * time.Hour, Retention: daily, partition: monthPartition},
+	}
+}
+
+// record is a single entry of a tier. Raw samples are stored with
+// min = max = sum and count = 1.
+type record struct {
+	Time   int64 // milliseconds since the epoch
+	Series uint32
+	Min    float64
+	Max    float64
+	Sum    float64
+	Count  uint32
+}
+
+const (
+	rawRecordSize       = 8 + 4 + 8
+	aggregateRecordSize = 8 + 4 + 3*8 + 4
+)
+
+func (r *record) merge(o record) {
+	if r.Count == 0 {
+		*r = o
+		return
+	}
+	r.Min = math.Min(r.Min, o.Min)
+	r.Max = math.Max(r.Max, o.Max)
+	r.Sum += o.Sum
+	r.Count += o.Count
+}
+
+func (r record) appendTo(b []byte, raw bool) []byte {
+	b = binary.LittleEndian.AppendUint64(b, uint64(r.Time))
+	b = binary.LittleEndian.AppendUint32(b, r.Series)
+	if raw {
+		return binary.LittleEndian.AppendUint64(b, math.Float64bits(r.Sum))
+	}
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(r.Min))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(r.Max))
+	b = binary.LittleEndian.AppendUint64(b, math.Float64bits(r.Sum))
+	return binary.LittleEndian.AppendUint32(b, r.Count)
+}
+
+func decodeRecord(b []byte, raw bool) record {
+	r := record{
+		Time:   int64(binary.LittleEndian.Uint64(b)),
+		Series: binary.LittleEndian.Uint32(b[8:]),
+	}
+	if raw {
+		v := math.Float64frombits(binary.LittleEndian.Uint64(b[12:]))
+		r.Min, r.Max, r.Sum, r.Count = v, v, v, 1
+		return r
+	}
+	r.Min = math.Float64frombits(binary.LittleEndian.Uint64(b[12:]))
+	r.Max = math.Float64frombits(binary.LittleEndian.Uint64(b[20:]))
+	r.Sum = math.Float64frombits(binary.LittleEndian.Uint64(b[28:]))
+	r.Count = binary.LittleEndian.Uint32(b[36:])
+	return r
+}
@@ -0,0 +1,79 @@
+package influx
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// See https://docs.influxdata.com/influxdb/v2/reference/syntax/line-protocol/.
+
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]any
+	Time        time.Time
+}
+
+// Line protocol cannot escape line breaks, so they are replaced by spaces.
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\ `, "\r", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\ `, "\r", `\ `)
+	stringEscaper      = strings.NewReplacer(`"`, `\"`, `\`, `\\`)
+)
+
+func (p Point) AppendLine(b []byte) ([]byte, error) {
+	if len(p.Fields) == 0 {
+		return b, fmt.Errorf("point %q has no fields", p.Measurement)
+	}
+
+	b = append(b, measurementEscaper.Replace(p.Measurement)...)
+	for _, k := range slices.Sorted(maps.Keys(p.Tags)) {
+		v := p.Tags[k]
+		if v == "" {
+			continue
+		}
+		b = append(b, ',')
+		b = append(b, tagEscaper.Replace(k)...)
+		b = append(b, '=')
+		b = append(b, tagEscaper.Replace(v)...)
+	}
+
+	sep := byte(' ')
+	for _, k := range slices.Sorted(maps.Keys(p.Fields)) {
+		b = append(b, sep)
+		sep = ','
+		b = append(b, tagEscaper.Replace(k)...)
+		b = append(b, '=')
+		switch v := p.Fields[k].(type) {
+		case float64:
+			b = strconv.AppendFloat(b, v, 'f', -1, 64)
+		case float32:
+			b = strconv.AppendFloat(b, float64(v), 'f', -1, 32)
+		case int:
+			b = strconv.AppendInt(b, int64(v), 10)
+			b = append(b, 'i')
+		case int64:
+			b = strconv.AppendInt(b, v, 10)
+			b = append(b, 'i')
+		case bool:
+			b = strconv.AppendBool(b, v)
+		case string:
+			b = append(b, '"')
+			b = append(b, stringEscaper.Replace(v)...)
+			b = append(b, '"')
+		default:
+			return b, fmt.Errorf("unsupported field type %T", v)
+		}
+	}
+
+	if !p.Time.IsZero() {
+		b = append(b, ' ')
+		b = strconv.AppendInt(b, p.Time.UnixNano(), 10)
+	}
+
+	return append(b, '\n'), nil
+}
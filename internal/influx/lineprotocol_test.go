@@ -0,0 +1,58 @@
+package influx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointAppendLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		point    Point
+		expected string
+	}{
+		{
+			name: "types",
+			point: Point{
+				Measurement: "state",
+				Fields:      map[string]any{"f": 1.5, "i": 2, "b": true, "s": "on"},
+				Time:        time.Unix(1, 0),
+			},
+			expected: "state b=true,f=1.5,i=2i,s=\"on\" 1000000000\n",
+		},
+		{
+			name: "escaping",
+			point: Point{
+				Measurement: "real data",
+				Tags:        map[string]string{"unit": "k W", "metric": "a=b,c", "empty": ""},
+				Fields:      map[string]any{"text": `say "hi" \o/`},
+			},
+			expected: "real\\ data,metric=a\\=b\\,c,unit=k\\ W text=\"say \\\"hi\\\" \\\\o/\"\n",
+		},
+		{
+			name: "line breaks",
+			point: Point{
+				Measurement: "real\ndata",
+				Tags:        map[string]string{"model": "SH10RT\r\nV2"},
+				Fields:      map[string]any{"v": 1},
+			},
+			expected: "real\\ data,model=SH10RT\\ \\ V2 v=1i\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b, err := test.point.AppendLine(nil)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, string(b))
+		})
+	}
+}
+
+func TestPointAppendLineNoFields(t *testing.T) {
+	_, err := Point{Measurement: "real"}.AppendLine(nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,127 @@
+package influx
+
+import (
+	"maps"
+	"regexp"
+	"strconv"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/poll"
+)
+
+const (
+	SerialTag = "serial"
+	DeviceTag = "device"
+	ModelTag  = "model"
+	MetricTag = "metric"
+	// UnitTag only applies to the points of single metrics.
+	UnitTag = "unit"
+)
+
+type pointOptions struct {
+	tags      map[string]bool
+	extraTags map[string]string
+}
+
+func (o pointOptions) newTags(sample redgiant.Snapshot, ds *redgiant.DeviceSnapshot) map[string]string {
+	tags := maps.Clone(o.extraTags)
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	if o.tags[SerialTag] {
+		tags[SerialTag] = sample.About.SerialNumber
+	}
+	if ds != nil && o.tags[DeviceTag] {
+		tags[DeviceTag] = strconv.Itoa(ds.Device.ID)
+	}
+	if ds != nil && o.tags[ModelTag] {
+		tags[ModelTag] = ds.Device.Model
+	}
+	return tags
+}
+
+var decimalRe = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// measurementValue returns the field name and value for a real measurement.
+// Values that are not decimal numbers, e.g. running states or the "--"
+// placeholder, are stored in a separate field to avoid field type conflicts.
+// This includes the NaN, infinities and hex floats ParseFloat accepts, which
+// the line protocol cannot represent.
+func measurementValue(value string) (string, any) {
+	if !decimalRe.MatchString(value) {
+		return "text", value
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		return "value", f
+	}
+	return "text", value
+}
+
+// samplePoints converts a sample into points. If the metric tag is enabled,
+// every measurement results in its own point. Otherwise, all measurements of a
+// device are stored as fields of a single point.
+func samplePoints(sample redgiant.Snapshot, o pointOptions) []Point {
+	ps := []Point{{
+		Measurement: "state",
+		Tags:        o.newTags(sample, nil),
+		Fields: map[string]any{
+			"total_faults":         sample.State.TotalFaults,
+			"total_alarms":         sample.State.TotalAlarms,
+			"wireless_connection":  sample.State.WirelessConnection,
+			"wifi_connection":      sample.State.WifiConnection,
+			"ethernet1_connection": sample.State.Ethernet1Connection,
+			"ethernet2_connection": sample.State.Ethernet2Connection,
+			"cloud_connection":     sample.State.CloudConnection,
+		},
+		Time: sample.Time,
+	}}
+
+	for _, ds := range sample.Devices {
+		if o.tags[MetricTag] {
+			for _, m := range ds.Real {
+				tags := o.newTags(sample, &ds)
+				tags[MetricTag] = m.I18NCode
+				if o.tags[UnitTag] {
+					tags[UnitTag] = m.Unit
+				}
+				k, v := measurementValue(m.Value)
+				ps = append(ps, Point{Measurement: "real", Tags: tags, Fields: map[string]any{k: v}, Time: sample.Time})
+			}
+			for _, m := range ds.Direct {
+				tags := o.newTags(sample, &ds)
+				tags[MetricTag] = m.I18NCode
+				ps = append(ps, Point{
+					Measurement: "direct",
+					Tags:        tags,
+					Fields:      map[string]any{"voltage": m.Voltage, "current": m.Current},
+					Time:        sample.Time,
+				})
+			}
+			continue
+		}
+
+		if len(ds.Real) > 0 {
+			fields := make(map[string]any, len(ds.Real))
+			for _, m := range ds.Real {
+				k, v := measurementValue(m.Value)
+				if k == "value" {
+					fields[poll.MetricID(m.I18NCode)] = v
+				} else {
+					fields[poll.MetricID(m.I18NCode)+"_text"] = v
+				}
+			}
+			ps = append(ps, Point{Measurement: "real", Tags: o.newTags(sample, &ds), Fields: fields, Time: sample.Time})
+		}
+		if len(ds.Direct) > 0 {
+			fields := make(map[string]any, 2*len(ds.Direct))
+			for _, m := range ds.Direct {
+				id := poll.MetricID(m.I18NCode)
+				fields[id+"_voltage"] = m.Voltage
+				fields[id+"_current"] = m.Current
+			}
+			ps = append(ps, Point{Measurement: "direct", Tags: o.newTags(sample, &ds), Fields: fields, Time: sample.Time})
+		}
+	}
+
+	return ps
+}
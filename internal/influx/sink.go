@@ -0,0 +1,111 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/poll"
+	"github.com/rs/zerolog"
+)
+
+type SinkOptions struct {
+	// Tags selects which of SerialTag, DeviceTag, ModelTag, MetricTag and
+	// UnitTag are attached to the points.
+	Tags      []string
+	ExtraTags map[string]string
+	// BatchSize is the number of lines after which the buffer is flushed. It
+	// also limits the number of lines sent in a single request.
+	BatchSize     int
+	FlushInterval time.Duration
+	// BufferSize is the maximum number of lines retained while the target is
+	// unavailable. If exceeded, the oldest lines are dropped.
+	BufferSize int
+}
+
+type Sink struct {
+	w    Writer
+	opts SinkOptions
+	po   pointOptions
+	log  zerolog.Logger
+
+	mu        sync.Mutex
+	lines     [][]byte
+	lastFlush time.Time
+}
+
+var _ poll.Sink = &Sink{}
+
+func NewSink(w Writer, opts SinkOptions, logger zerolog.Logger) *Sink {
+	po := pointOptions{tags: map[string]bool{}, extraTags: opts.ExtraTags}
+	for _, t := range opts.Tags {
+		po.tags[t] = true
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+	return &Sink{w: w, opts: opts, po: po, log: logger, lastFlush: time.Now()}
+}
+
+func (s *Sink) Write(ctx context.Context, sample redgiant.Snapshot) error {
+	s.log.Trace().Msg("Sink.Write()")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, p := range samplePoints(sample, s.po) {
+		l, err := p.AppendLine(nil)
+		if err != nil {
+			s.log.Debug().Err(err).Msg("point dropped")
+			continue
+		}
+		s.lines = append(s.lines, l)
+	}
+
+	if len(s.lines) < s.opts.BatchSize && time.Since(s.lastFlush) < s.opts.FlushInterval {
+		return nil
+	}
+	return s.flush(ctx)
+}
+
+// flush writes the buffered lines in batches. Lines that could not be written
+// are kept for the next attempt, unless the batch was rejected and would be
+// rejected again. Must be called with s.mu held.
+func (s *Sink) flush(ctx context.Context) error {
+	s.lastFlush = time.Now()
+
+	for len(s.lines) > 0 {
+		n := min(len(s.lines), s.opts.BatchSize)
+		if err := s.w.Write(ctx, bytes.Join(s.lines[:n], nil)); err != nil && isRetryable(err) {
+			s.truncate()
+			return err
+		} else if err != nil {
+			s.log.Error().Err(err).Int("lines", n).Msg("batch rejected, dropped lines")
+		}
+		s.lines = s.lines[n:]
+	}
+	s.lines = nil
+	return nil
+}
+
+func (s *Sink) truncate() {
+	if s.opts.BufferSize <= 0 || len(s.lines) <= s.opts.BufferSize {
+		return
+	}
+	dropped := len(s.lines) - s.opts.BufferSize
+	s.lines = s.lines[dropped:]
+	s.log.Warn().Int("lines", dropped).Msg("buffer full, dropped oldest lines")
+}
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	return errors.Join(s.flush(ctx), s.w.Close())
+}
@@ -0,0 +1,139 @@
+package influx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeWriter struct {
+	errs    []error
+	batches []string
+}
+
+func (w *fakeWriter) Write(_ context.Context, lines []byte) error {
+	if len(w.errs) > 0 {
+		err := w.errs[0]
+		w.errs = w.errs[1:]
+		if err != nil {
+			return err
+		}
+	}
+	w.batches = append(w.batches, string(lines))
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+func TestSinkFlush(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		retained bool
+	}{
+		{name: "network", err: errors.New("connection refused"), retained: true},
+		{name: "server", err: &StatusError{StatusCode: http.StatusServiceUnavailable}, retained: true},
+		{name: "too many requests", err: &StatusError{StatusCode: http.StatusTooManyRequests}, retained: true},
+		{name: "bad request", err: &StatusError{StatusCode: http.StatusBadRequest}},
+		{name: "unauthorized", err: &StatusError{StatusCode: http.StatusUnauthorized}},
+		{name: "not found", err: &StatusError{StatusCode: http.StatusNotFound}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := &fakeWriter{errs: []error{test.err}}
+			s := NewSink(w, SinkOptions{BatchSize: 1}, zerolog.Nop())
+			ctx := context.Background()
+
+			err := s.Write(ctx, redgiant.Snapshot{Time: time.Unix(1, 0)})
+			if test.retained {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, s.Write(ctx, redgiant.Snapshot{Time: time.Unix(2, 0)}))
+			var written []string
+			for _, b := range w.batches {
+				written = append(written, strings.Fields(b)[len(strings.Fields(b))-1])
+			}
+			if test.retained {
+				assert.Equal(t, []string{"1000000000", "2000000000"}, written)
+			} else {
+				assert.Equal(t, []string{"2000000000"}, written)
+			}
+		})
+	}
+}
+
+func TestMeasurementValue(t *testing.T) {
+	tests := []struct {
+		value string
+		field string
+	}{
+		{value: "1.5", field: "value"},
+		{value: "-0.25", field: "value"},
+		{value: "1e3", field: "value"},
+		{value: ".5", field: "value"},
+		{value: "--", field: "text"},
+		{value: "", field: "text"},
+		{value: "NaN", field: "text"},
+		{value: "Inf", field: "text"},
+		{value: "-infinity", field: "text"},
+		{value: "0x1p-2", field: "text"},
+		{value: "1e400", field: "text"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			field, _ := measurementValue(test.value)
+			assert.Equal(t, test.field, field)
+		})
+	}
+}
+
+func TestSamplePointsTags(t *testing.T) {
+	sample := redgiant.Snapshot{
+		About: redgiant.About{SerialNumber: "A1"},
+		Devices: []redgiant.DeviceSnapshot{{
+			Device: redgiant.Device{ID: 1, Model: "SH10RT"},
+			Real:   []redgiant.RealMeasurement{{I18NCode: "I18N_COMMON_BATTERY_SOC", Value: "80", Unit: "%"}},
+		}},
+	}
+
+	tests := []struct {
+		name     string
+		tags     []string
+		expected map[string]string
+	}{
+		{
+			name:     "all",
+			tags:     []string{SerialTag, DeviceTag, ModelTag, MetricTag, UnitTag},
+			expected: map[string]string{"serial": "A1", "device": "1", "model": "SH10RT", "metric": "I18N_COMMON_BATTERY_SOC", "unit": "%"},
+		},
+		{
+			name:     "without model and unit",
+			tags:     []string{DeviceTag, MetricTag},
+			expected: map[string]string{"device": "1", "metric": "I18N_COMMON_BATTERY_SOC"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewSink(&fakeWriter{}, SinkOptions{Tags: test.tags}, zerolog.Nop())
+			ps := samplePoints(sample, s.po)
+			require.Len(t, ps, 2)
+			assert.Equal(t, test.expected, ps[1].Tags)
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package influx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+type Writer interface {
+	Write(ctx context.Context, lines []byte) error
+	Close() error
+}
+
+// StatusError is returned by the HTTP writer if the write was rejected.
+type StatusError struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Status, e.Message)
+}
+
+// isRetryable reports whether writing the lines again might succeed. Lines
+// rejected by the server, e.g. since they are malformed or the credentials
+// are invalid, are rejected again unless the server is overloaded or failed.
+// Any other error, e.g. of the network, is retryable.
+func isRetryable(err error) bool {
+	var se *StatusError
+	if errors.As(err, &se) {
+		return se.StatusCode >= 500 || se.StatusCode == http.StatusTooManyRequests
+	}
+	return true
+}
+
+type WriterOptions struct {
+	// URL selects the transport: http(s)://host:port for the v2 write API,
+	// udp://host:port for the UDP listener and file:///path to append to a
+	// local file.
+	URL     string
+	Token   string
+	Org     string
+	Bucket  string
+	Timeout time.Duration
+}
+
+func NewWriter(o WriterOptions) (Writer, error) {
+	u, err := url.Parse(o.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return newHTTPWriter(u, o), nil
+	case "udp":
+		return newUDPWriter(u)
+	case "file":
+		return newFileWriter(u.Path)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %q", u.Scheme)
+	}
+}
+
+type httpWriter struct {
+	u     string
+	token string
+	c     *http.Client
+}
+
+func newHTTPWriter(u *url.URL, o WriterOptions) *httpWriter {
+	wu := u.JoinPath("/api/v2/write")
+	q := wu.Query()
+	q.Set("org", o.Org)
+	q.Set("bucket", o.Bucket)
+	q.Set("precision", "ns")
+	wu.RawQuery = q.Encode()
+	return &httpWriter{u: wu.String(), token: o.Token, c: &http.Client{Timeout: o.Timeout}}
+}
+
+func (w *httpWriter) Write(ctx context.Context, lines []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.u, bytes.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if w.token != "" {
+		req.Header.Set("Authorization", "Token "+w.token)
+	}
+
+	r, err := w.c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	if r.StatusCode >= 200 && r.StatusCode < 300 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(r.Body, 1024))
+	return &StatusError{StatusCode: r.StatusCode, Status: r.Status, Message: strings.TrimSpace(string(msg))}
+}
+
+func (w *httpWriter) Close() error {
+	w.c.CloseIdleConnections()
+	return nil
+}
+
+// maxDatagramSize keeps datagrams below the common MTU to avoid fragmentation.
+const maxDatagramSize = 1400
+
+type udpWriter struct {
+	conn net.Conn
+}
+
+func newUDPWriter(u *url.URL) (*udpWriter, error) {
+	conn, err := net.Dial("udp", u.Host)
+	if err != nil {
+		return nil, err
+	}
+	return &udpWriter{conn: conn}, nil
+}
+
+func (w *udpWriter) Write(ctx context.Context, lines []byte) error {
+	for len(lines) > 0 {
+		n := len(lines)
+		if n > maxDatagramSize {
+			// split at line boundaries, since the listener parses each datagram separately
+			if i := bytes.LastIndexByte(lines[:maxDatagramSize], '\n'); i >= 0 {
+				n = i + 1
+			} else if i := bytes.IndexByte(lines, '\n'); i >= 0 {
+				n = i + 1
+			}
+		}
+		if _, err := w.conn.Write(lines[:n]); err != nil {
+			return err
+		}
+		lines = lines[n:]
+	}
+	return nil
+}
+
+func (w *udpWriter) Close() error {
+	return w.conn.Close()
+}
+
+type fileWriter struct {
+	f *os.File
+}
+
+func newFileWriter(path string) (*fileWriter, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &fileWriter{f: f}, nil
+}
+
+func (w *fileWriter) Write(ctx context.Context, lines []byte) error {
+	_, err := w.f.Write(lines)
+	return err
+}
+
+func (w *fileWriter) Close() error {
+	return w.f.Close()
+}
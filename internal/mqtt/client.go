@@ -0,0 +1,248 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+type ClientOptions struct {
+	// Broker is the URL of the broker, e.g. tcp://localhost:1883. The schemes
+	// ssl, tls and mqtts connect through TLS.
+	Broker         string
+	ClientID       string
+	Username       string
+	Password       string
+	KeepAlive      time.Duration
+	ConnectTimeout time.Duration
+	TLSConfig      *tls.Config
+	Will           *Message
+}
+
+type Client struct {
+	opts ClientOptions
+	log  zerolog.Logger
+
+	mu     sync.Mutex
+	conn   net.Conn
+	lost   chan struct{}
+	nextID uint16
+	acks   map[uint16]chan struct{}
+}
+
+func NewClient(opts ClientOptions, logger zerolog.Logger) *Client {
+	if opts.ConnectTimeout == 0 {
+		opts.ConnectTimeout = 10 * time.Second
+	}
+	return &Client{opts: opts, log: logger, acks: map[uint16]chan struct{}{}}
+}
+
+func (c *Client) dial(ctx context.Context) (net.Conn, error) {
+	u, err := url.Parse(c.opts.Broker)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &net.Dialer{Timeout: c.opts.ConnectTimeout}
+	switch u.Scheme {
+	case "tcp", "mqtt":
+		return d.DialContext(ctx, "tcp", u.Host)
+	case "ssl", "tls", "mqtts":
+		td := &tls.Dialer{NetDialer: d, Config: c.opts.TLSConfig}
+		return td.DialContext(ctx, "tcp", u.Host)
+	default:
+		return nil, fmt.Errorf("unsupported broker scheme %q", u.Scheme)
+	}
+}
+
+func (c *Client) Connect(ctx context.Context) error {
+	c.log.Trace().Str("broker", c.opts.Broker).Msg("Client.Connect()")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != nil {
+		return nil
+	}
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+
+	conn.SetDeadline(time.Now().Add(c.opts.ConnectTimeout))
+	p := newConnectPacket(connectOptions{
+		clientID:  c.opts.ClientID,
+		username:  c.opts.Username,
+		password:  c.opts.Password,
+		keepAlive: uint16(c.opts.KeepAlive / time.Second),
+		will:      c.opts.Will,
+	})
+	if _, err := conn.Write(p.encode()); err != nil {
+		conn.Close()
+		return err
+	}
+
+	r := bufio.NewReader(conn)
+	ack, err := readPacket(r)
+	if err == nil {
+		err = parseConnack(ack)
+	}
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	conn.SetDeadline(time.Time{})
+
+	c.conn = conn
+	c.lost = make(chan struct{})
+	go c.read(conn, r, c.lost)
+	if c.opts.KeepAlive > 0 {
+		go c.keepAlive(conn, c.lost)
+	}
+
+	c.log.Info().Str("broker", c.opts.Broker).Msg("connected to broker")
+	return nil
+}
+
+func (c *Client) IsConnected() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.conn != nil
+}
+
+func (c *Client) read(conn net.Conn, r *bufio.Reader, lost chan struct{}) {
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			c.connectionLost(conn, lost, err)
+			return
+		}
+
+		switch p.typ {
+		case pubackPacket:
+			if len(p.body) != 2 {
+				continue
+			}
+			id := binary.BigEndian.Uint16(p.body)
+			c.mu.Lock()
+			if ch, ok := c.acks[id]; ok {
+				close(ch)
+				delete(c.acks, id)
+			}
+			c.mu.Unlock()
+		case pingrespPacket:
+		default:
+			c.log.Debug().Uint8("type", p.typ).Msg("unexpected packet dropped")
+		}
+	}
+}
+
+func (c *Client) keepAlive(conn net.Conn, lost chan struct{}) {
+	ticker := time.NewTicker(c.opts.KeepAlive * 3 / 4)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-lost:
+			return
+		case <-ticker.C:
+			if err := c.write(conn, packet{typ: pingreqPacket}); err != nil {
+				c.connectionLost(conn, lost, err)
+				return
+			}
+		}
+	}
+}
+
+func (c *Client) connectionLost(conn net.Conn, lost chan struct{}, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != conn {
+		return
+	}
+	c.log.Warn().Err(err).Str("broker", c.opts.Broker).Msg("connection to broker lost")
+	conn.Close()
+	c.conn = nil
+	close(lost)
+}
+
+func (c *Client) write(conn net.Conn, p packet) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn != conn {
+		return errors.New("not connected")
+	}
+	_, err := conn.Write(p.encode())
+	return err
+}
+
+func (c *Client) Publish(ctx context.Context, m Message) error {
+	c.log.Trace().Str("topic", m.Topic).Msg("Client.Publish()")
+
+	c.mu.Lock()
+	conn, lost := c.conn, c.lost
+	if conn == nil {
+		c.mu.Unlock()
+		return errors.New("not connected")
+	}
+	var id uint16
+	var ack chan struct{}
+	if m.QoS > 0 {
+		c.nextID++
+		if c.nextID == 0 {
+			c.nextID++
+		}
+		id = c.nextID
+		ack = make(chan struct{})
+		c.acks[id] = ack
+	}
+	c.mu.Unlock()
+
+	if err := c.write(conn, newPublishPacket(m, id)); err != nil {
+		c.connectionLost(conn, lost, err)
+		return err
+	}
+	if ack == nil {
+		return nil
+	}
+
+	select {
+	case <-ack:
+		return nil
+	case <-lost:
+		return errors.New("connection lost before acknowledgement")
+	case <-ctx.Done():
+		c.mu.Lock()
+		delete(c.acks, id)
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (c *Client) Disconnect() error {
+	c.log.Trace().Msg("Client.Disconnect()")
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.conn == nil {
+		return nil
+	}
+	_, err := c.conn.Write(packet{typ: disconnectPacket}.encode())
+	c.conn.Close()
+	c.conn = nil
+	close(c.lost)
+	return err
+}
@@ -0,0 +1,89 @@
+package mqtt
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pmeier/redgiant"
+)
+
+// See https://www.home-assistant.io/integrations/mqtt/#mqtt-discovery for the
+// format of the discovery messages.
+
+type sensorClass struct {
+	DeviceClass string
+	StateClass  string
+	Unit        string
+}
+
+var unitSensorClasses = map[string]sensorClass{
+	"W":    {DeviceClass: "power", StateClass: "measurement", Unit: "W"},
+	"kW":   {DeviceClass: "power", StateClass: "measurement", Unit: "kW"},
+	"Wh":   {DeviceClass: "energy", StateClass: "total_increasing", Unit: "Wh"},
+	"kWh":  {DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"},
+	"MWh":  {DeviceClass: "energy", StateClass: "total_increasing", Unit: "MWh"},
+	"var":  {DeviceClass: "reactive_power", StateClass: "measurement", Unit: "var"},
+	"kvar": {DeviceClass: "reactive_power", StateClass: "measurement", Unit: "kvar"},
+	"VA":   {DeviceClass: "apparent_power", StateClass: "measurement", Unit: "VA"},
+	"kVA":  {DeviceClass: "apparent_power", StateClass: "measurement", Unit: "kVA"},
+	"V":    {DeviceClass: "voltage", StateClass: "measurement", Unit: "V"},
+	"A":    {DeviceClass: "current", StateClass: "measurement", Unit: "A"},
+	"Hz":   {DeviceClass: "frequency", StateClass: "measurement", Unit: "Hz"},
+	"℃":    {DeviceClass: "temperature", StateClass: "measurement", Unit: "°C"},
+	"°C":   {DeviceClass: "temperature", StateClass: "measurement", Unit: "°C"},
+	"h":    {DeviceClass: "duration", StateClass: "total_increasing", Unit: "h"},
+	"kΩ":   {StateClass: "measurement", Unit: "kΩ"},
+	"%":    {StateClass: "measurement", Unit: "%"},
+}
+
+func classifySensor(i18nCode string, unit string) sensorClass {
+	sc, ok := unitSensorClasses[strings.TrimSpace(unit)]
+	if !ok {
+		return sensorClass{Unit: unit}
+	}
+	if sc.Unit == "%" && (strings.Contains(i18nCode, "SOC") || strings.Contains(i18nCode, "BATTERY_LEVEL")) {
+		sc.DeviceClass = "battery"
+	}
+	return sc
+}
+
+type discoveryDevice struct {
+	Identifiers  []string `json:"identifiers"`
+	Name         string   `json:"name"`
+	Manufacturer string   `json:"manufacturer"`
+	Model        string   `json:"model,omitempty"`
+	SerialNumber string   `json:"serial_number,omitempty"`
+	SWVersion    string   `json:"sw_version,omitempty"`
+}
+
+type discoveryAvailability struct {
+	Topic string `json:"topic"`
+}
+
+type discoveryConfig struct {
+	Name              string                  `json:"name"`
+	UniqueID          string                  `json:"unique_id"`
+	ObjectID          string                  `json:"object_id"`
+	StateTopic        string                  `json:"state_topic"`
+	Availability      []discoveryAvailability `json:"availability"`
+	AvailabilityMode  string                  `json:"availability_mode"`
+	DeviceClass       string                  `json:"device_class,omitempty"`
+	StateClass        string                  `json:"state_class,omitempty"`
+	UnitOfMeasurement string                  `json:"unit_of_measurement,omitempty"`
+	Device            discoveryDevice         `json:"device"`
+}
+
+func newDiscoveryDevice(about redgiant.About, d redgiant.Device) discoveryDevice {
+	name := d.Name
+	if name == "" {
+		name = d.Model
+	}
+	return discoveryDevice{
+		Identifiers:  []string{"redgiant_" + about.SerialNumber + "_" + strconv.Itoa(d.ID)},
+		Name:         name,
+		Manufacturer: "Sungrow",
+		Model:        d.Model,
+		SerialNumber: d.SerialNumber,
+		SWVersion:    about.SoftwareVersion,
+	}
+}
@@ -0,0 +1,142 @@
+package mqtt
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// broker is a stand-in for an MQTT broker that accepts a single client and
+// records everything it publishes.
+type broker struct {
+	l        net.Listener
+	messages chan Message
+}
+
+func newBroker(t *testing.T) *broker {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+
+	b := &broker{l: l, messages: make(chan Message, 100)}
+	go b.serve()
+	return b
+}
+
+func (b *broker) URL() string {
+	return "tcp://" + b.l.Addr().String()
+}
+
+func (b *broker) serve() {
+	conn, err := b.l.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	for {
+		p, err := readPacket(r)
+		if err != nil {
+			return
+		}
+
+		switch p.typ {
+		case connectPacket:
+			conn.Write(packet{typ: connackPacket, body: []byte{0, 0}}.encode())
+		case publishPacket:
+			m, id, err := parsePublish(p)
+			if err != nil {
+				return
+			}
+			if m.QoS > 0 {
+				conn.Write(packet{typ: pubackPacket, body: []byte{byte(id >> 8), byte(id)}}.encode())
+			}
+			b.messages <- m
+		case pingreqPacket:
+			conn.Write(packet{typ: pingrespPacket}.encode())
+		case disconnectPacket:
+			return
+		}
+	}
+}
+
+func (b *broker) receive(t *testing.T, n int) map[string]Message {
+	ms := map[string]Message{}
+	for range n {
+		select {
+		case m := <-b.messages:
+			ms[m.Topic] = m
+		case <-time.After(time.Second):
+			require.FailNow(t, "timeout waiting for message", "received %d of %d", len(ms), n)
+		}
+	}
+	return ms
+}
+
+func TestSink(t *testing.T) {
+	b := newBroker(t)
+	ctx := context.Background()
+
+	s := NewSink(
+		ClientOptions{Broker: b.URL(), ClientID: "test"},
+		SinkOptions{TopicPrefix: "redgiant", Discovery: true, DiscoveryPrefix: "homeassistant", QoS: 1},
+		zerolog.Nop(),
+	)
+	defer s.Close()
+
+	require.NoError(t, s.Availability(ctx, true))
+	require.NoError(t, s.Write(ctx, redgiant.Snapshot{
+		About: redgiant.About{SerialNumber: "A1"},
+		Devices: []redgiant.DeviceSnapshot{{
+			Device: redgiant.Device{ID: 1, Model: "SH10RT"},
+			Real: []redgiant.RealMeasurement{
+				{I18NCode: "I18N_COMMON_TOTAL_ACTIVE_POWER", Name: "Total Active Power", Value: "1.23", Unit: "kW"},
+			},
+		}},
+	}))
+
+	ms := b.receive(t, 4)
+
+	assert.Equal(t, "online", string(ms["redgiant/availability"].Payload))
+	assert.True(t, ms["redgiant/availability"].Retain)
+	assert.Equal(t, "online", string(ms["redgiant/A1/availability"].Payload))
+	assert.Equal(t, "1.23", string(ms["redgiant/A1/1/common_total_active_power"].Payload))
+
+	c, ok := ms["homeassistant/sensor/redgiant_A1_1_common_total_active_power/config"]
+	require.True(t, ok)
+	var dc discoveryConfig
+	require.NoError(t, json.Unmarshal(c.Payload, &dc))
+	assert.Equal(t, "redgiant/A1/1/common_total_active_power", dc.StateTopic)
+	assert.Equal(t, "power", dc.DeviceClass)
+	assert.Equal(t, "measurement", dc.StateClass)
+	assert.Equal(t, "kW", dc.UnitOfMeasurement)
+}
+
+func TestClassifySensor(t *testing.T) {
+	tests := []struct {
+		i18nCode string
+		unit     string
+		expected sensorClass
+	}{
+		{i18nCode: "I18N_COMMON_DAILY_PV_YIELD", unit: "kWh", expected: sensorClass{DeviceClass: "energy", StateClass: "total_increasing", Unit: "kWh"}},
+		{i18nCode: "I18N_COMMON_AIR_TEM_INSIDE_MACHINE", unit: "℃", expected: sensorClass{DeviceClass: "temperature", StateClass: "measurement", Unit: "°C"}},
+		{i18nCode: "I18N_COMMON_BATTERY_SOC", unit: "%", expected: sensorClass{DeviceClass: "battery", StateClass: "measurement", Unit: "%"}},
+		{i18nCode: "I18N_COMMON_POWER_FACTOR", unit: "%", expected: sensorClass{StateClass: "measurement", Unit: "%"}},
+		{i18nCode: "I18N_COMMON_RUNNING_STATE", unit: "", expected: sensorClass{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.i18nCode, func(t *testing.T) {
+			assert.Equal(t, test.expected, classifySensor(test.i18nCode, test.unit))
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// This file implements the small subset of MQTT 3.1.1 needed to publish
+// messages. See https://docs.oasis-open.org/mqtt/mqtt/v3.1.1/mqtt-v3.1.1.html.
+
+const (
+	connectPacket    byte = 1
+	connackPacket    byte = 2
+	publishPacket    byte = 3
+	pubackPacket     byte = 4
+	pingreqPacket    byte = 12
+	pingrespPacket   byte = 13
+	disconnectPacket byte = 14
+)
+
+type packet struct {
+	typ   byte
+	flags byte
+	body  []byte
+}
+
+func (p packet) encode() []byte {
+	b := []byte{p.typ<<4 | p.flags&0x0f}
+	b = appendRemainingLength(b, len(p.body))
+	return append(b, p.body...)
+}
+
+func appendRemainingLength(b []byte, n int) []byte {
+	for {
+		d := byte(n % 128)
+		n /= 128
+		if n > 0 {
+			d |= 0x80
+		}
+		b = append(b, d)
+		if n == 0 {
+			return b
+		}
+	}
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+func readPacket(r *bufio.Reader) (packet, error) {
+	h, err := r.ReadByte()
+	if err != nil {
+		return packet{}, err
+	}
+
+	var n, m int
+	for i := 0; ; i++ {
+		if i == 4 {
+			return packet{}, errors.New("malformed remaining length")
+		}
+		d, err := r.ReadByte()
+		if err != nil {
+			return packet{}, err
+		}
+		n += int(d&0x7f) << (7 * m)
+		m++
+		if d&0x80 == 0 {
+			break
+		}
+	}
+
+	body := make([]byte, n)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return packet{}, err
+	}
+
+	return packet{typ: h >> 4, flags: h & 0x0f, body: body}, nil
+}
+
+type connectOptions struct {
+	clientID  string
+	username  string
+	password  string
+	keepAlive uint16
+	will      *Message
+}
+
+func newConnectPacket(o connectOptions) packet {
+	var flags byte = 0x02 // clean session
+	if o.will != nil {
+		flags |= 0x04 | o.will.QoS<<3
+		if o.will.Retain {
+			flags |= 0x20
+		}
+	}
+	if o.username != "" {
+		flags |= 0x80
+		if o.password != "" {
+			flags |= 0x40
+		}
+	}
+
+	b := appendString(nil, "MQTT")
+	b = append(b, 4, flags)
+	b = binary.BigEndian.AppendUint16(b, o.keepAlive)
+	b = appendString(b, o.clientID)
+	if o.will != nil {
+		b = appendString(b, o.will.Topic)
+		b = appendString(b, string(o.will.Payload))
+	}
+	if o.username != "" {
+		b = appendString(b, o.username)
+		if o.password != "" {
+			b = appendString(b, o.password)
+		}
+	}
+	return packet{typ: connectPacket, body: b}
+}
+
+var connackReturnCodes = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+func parseConnack(p packet) error {
+	if p.typ != connackPacket || len(p.body) != 2 {
+		return fmt.Errorf("expected CONNACK, got packet type %d", p.typ)
+	}
+	if code := p.body[1]; code != 0 {
+		if msg, ok := connackReturnCodes[code]; ok {
+			return fmt.Errorf("connection refused: %s", msg)
+		}
+		return fmt.Errorf("connection refused: return code %d", code)
+	}
+	return nil
+}
+
+type Message struct {
+	Topic   string
+	Payload []byte
+	QoS     byte
+	Retain  bool
+}
+
+func newPublishPacket(m Message, id uint16) packet {
+	flags := m.QoS << 1
+	if m.Retain {
+		flags |= 0x01
+	}
+	b := appendString(nil, m.Topic)
+	if m.QoS > 0 {
+		b = binary.BigEndian.AppendUint16(b, id)
+	}
+	return packet{typ: publishPacket, flags: flags, body: append(b, m.Payload...)}
+}
+
+func parsePublish(p packet) (Message, uint16, error) {
+	if len(p.body) < 2 {
+		return Message{}, 0, errors.New("malformed PUBLISH")
+	}
+	n := int(binary.BigEndian.Uint16(p.body))
+	b := p.body[2:]
+	if len(b) < n {
+		return Message{}, 0, errors.New("malformed PUBLISH")
+	}
+	m := Message{Topic: string(b[:n]), QoS: (p.flags >> 1) & 0x03, Retain: p.flags&0x01 == 1}
+	b = b[n:]
+
+	var id uint16
+	if m.QoS > 0 {
+		if len(b) < 2 {
+			return Message{}, 0, errors.New("malformed PUBLISH")
+		}
+		id = binary.BigEndian.Uint16(b)
+		b = b[2:]
+	}
+	m.Payload = b
+	return m, id, nil
+}
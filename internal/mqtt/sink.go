@@ -0,0 +1,225 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/poll"
+	"github.com/rs/zerolog"
+)
+
+type SinkOptions struct {
+	TopicPrefix       string
+	Discovery         bool
+	DiscoveryPrefix   string
+	QoS               byte
+	Retain            bool
+	ReconnectInterval time.Duration
+}
+
+// Sink publishes the measurements of each sample under
+// <prefix>/<serial>/<deviceID>/<metric>. Availability is reported on two
+// topics: <prefix>/availability is tied to the connection to the broker through
+// the last will, while <prefix>/<serial>/availability follows the connection to
+// the inverter.
+type Sink struct {
+	c    *Client
+	opts SinkOptions
+	log  zerolog.Logger
+
+	mu          sync.Mutex
+	serial      string
+	available   bool
+	discovered  map[string]bool
+	lastAttempt time.Time
+}
+
+var _ poll.AvailabilitySink = &Sink{}
+
+func NewSink(copts ClientOptions, sopts SinkOptions, logger zerolog.Logger) *Sink {
+	copts.Will = &Message{
+		Topic:   sopts.TopicPrefix + "/availability",
+		Payload: []byte("offline"),
+		QoS:     sopts.QoS,
+		Retain:  true,
+	}
+	return &Sink{
+		c:          NewClient(copts, logger),
+		opts:       sopts,
+		log:        logger,
+		discovered: map[string]bool{},
+	}
+}
+
+func (s *Sink) publish(ctx context.Context, topic string, payload []byte, retain bool) error {
+	return s.c.Publish(ctx, Message{Topic: topic, Payload: payload, QoS: s.opts.QoS, Retain: retain})
+}
+
+func availabilityPayload(available bool) []byte {
+	if available {
+		return []byte("online")
+	}
+	return []byte("offline")
+}
+
+// ensureConnected (re-)connects to the broker if necessary. Attempts are spaced
+// by the reconnect interval so an unreachable broker does not stall sampling.
+// Must be called with s.mu held.
+func (s *Sink) ensureConnected(ctx context.Context) error {
+	if s.c.IsConnected() {
+		return nil
+	}
+	if time.Since(s.lastAttempt) < s.opts.ReconnectInterval {
+		return errors.New("not connected to broker")
+	}
+	s.lastAttempt = time.Now()
+
+	if err := s.c.Connect(ctx); err != nil {
+		return err
+	}
+
+	// A new session might talk to a restarted broker that lost the retained
+	// messages, so everything is announced again.
+	clear(s.discovered)
+	if err := s.publish(ctx, s.opts.TopicPrefix+"/availability", availabilityPayload(true), true); err != nil {
+		return err
+	}
+	if s.serial != "" {
+		return s.publishAvailability(ctx)
+	}
+	return nil
+}
+
+func (s *Sink) publishAvailability(ctx context.Context) error {
+	return s.publish(ctx, s.opts.TopicPrefix+"/"+s.serial+"/availability", availabilityPayload(s.available), true)
+}
+
+func (s *Sink) Availability(ctx context.Context, available bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := s.available != available
+	s.available = available
+	if err := s.ensureConnected(ctx); err != nil {
+		return err
+	}
+	if !changed || s.serial == "" {
+		return nil
+	}
+	return s.publishAvailability(ctx)
+}
+
+type metric struct {
+	id    string
+	code  string
+	name  string
+	value string
+	unit  string
+}
+
+func deviceMetrics(ds redgiant.DeviceSnapshot) []metric {
+	ms := make([]metric, 0, len(ds.Real)+2*len(ds.Direct))
+	for _, m := range ds.Real {
+		ms = append(ms, metric{id: poll.MetricID(m.I18NCode), code: m.I18NCode, name: m.Name, value: m.Value, unit: m.Unit})
+	}
+	for _, m := range ds.Direct {
+		name := m.Name
+		if name == "" {
+			name = m.I18NCode
+		}
+		id := poll.MetricID(m.I18NCode)
+		ms = append(ms,
+			metric{id: id + "_voltage", code: m.I18NCode, name: name + " Voltage", value: formatFloat(m.Voltage), unit: m.VoltageUnit},
+			metric{id: id + "_current", code: m.I18NCode, name: name + " Current", value: formatFloat(m.Current), unit: m.CurrentUnit},
+		)
+	}
+	return ms
+}
+
+func formatFloat(f float32) string {
+	return strconv.FormatFloat(float64(f), 'f', -1, 32)
+}
+
+func (s *Sink) Write(ctx context.Context, sample redgiant.Snapshot) error {
+	s.log.Trace().Msg("Sink.Write()")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.ensureConnected(ctx); err != nil {
+		return err
+	}
+
+	if s.serial != sample.About.SerialNumber {
+		s.serial = sample.About.SerialNumber
+		if err := s.publishAvailability(ctx); err != nil {
+			return err
+		}
+	}
+
+	var errs []error
+	for _, ds := range sample.Devices {
+		deviceTopic := strings.Join([]string{s.opts.TopicPrefix, s.serial, strconv.Itoa(ds.Device.ID)}, "/")
+		for _, m := range deviceMetrics(ds) {
+			stateTopic := deviceTopic + "/" + m.id
+			if s.opts.Discovery && !s.discovered[stateTopic] {
+				if err := s.publishDiscovery(ctx, sample.About, ds.Device, stateTopic, m); err != nil {
+					errs = append(errs, err)
+					continue
+				}
+				s.discovered[stateTopic] = true
+			}
+			if err := s.publish(ctx, stateTopic, []byte(m.value), s.opts.Retain); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Sink) publishDiscovery(ctx context.Context, about redgiant.About, d redgiant.Device, stateTopic string, m metric) error {
+	objectID := strings.Join([]string{"redgiant", about.SerialNumber, strconv.Itoa(d.ID), m.id}, "_")
+	sc := classifySensor(m.code, m.unit)
+	name := m.name
+	if name == "" {
+		name = m.code
+	}
+
+	b, err := json.Marshal(discoveryConfig{
+		Name:       name,
+		UniqueID:   objectID,
+		ObjectID:   objectID,
+		StateTopic: stateTopic,
+		Availability: []discoveryAvailability{
+			{Topic: s.opts.TopicPrefix + "/availability"},
+			{Topic: s.opts.TopicPrefix + "/" + about.SerialNumber + "/availability"},
+		},
+		AvailabilityMode:  "all",
+		DeviceClass:       sc.DeviceClass,
+		StateClass:        sc.StateClass,
+		UnitOfMeasurement: sc.Unit,
+		Device:            newDiscoveryDevice(about, d),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.publish(ctx, s.opts.DiscoveryPrefix+"/sensor/"+objectID+"/config", b, true)
+}
+
+func (s *Sink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.c.IsConnected() {
+		// A clean disconnect suppresses the last will, so the state is set explicitly.
+		s.publish(context.Background(), s.opts.TopicPrefix+"/availability", availabilityPayload(false), true)
+	}
+	return s.c.Disconnect()
+}
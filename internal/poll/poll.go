@@ -0,0 +1,121 @@
+package poll
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/rs/zerolog"
+)
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// MetricID turns an i18n code into an identifier suitable for topics, field
+// names and the like, e.g. I18N_COMMON_TOTAL_ACTIVE_POWER becomes
+// common_total_active_power.
+func MetricID(i18nCode string) string {
+	s := strings.ToLower(strings.TrimPrefix(i18nCode, "I18N_"))
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(s, "_"), "_")
+}
+
+type Sink interface {
+	Write(ctx context.Context, s redgiant.Snapshot) error
+	Close() error
+}
+
+// AvailabilitySink is implemented by sinks that want to be notified whether the
+// inverter could be reached during the last sampling pass.
+type AvailabilitySink interface {
+	Sink
+	Availability(ctx context.Context, available bool) error
+}
+
+// IdleSink is implemented by sinks that only need samples at times, e.g. while
+// clients are subscribed. Sampling is skipped while all sinks are idle.
+type IdleSink interface {
+	Sink
+	Idle() bool
+}
+
+type Poller struct {
+	rg       *redgiant.Redgiant
+	interval time.Duration
+	lang     redgiant.Language
+	sinks    []Sink
+	log      zerolog.Logger
+}
+
+func New(rg *redgiant.Redgiant, interval time.Duration, lang redgiant.Language, logger zerolog.Logger, sinks ...Sink) *Poller {
+	return &Poller{rg: rg, interval: interval, lang: lang, sinks: sinks, log: logger}
+}
+
+func (p *Poller) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		p.poll(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Idle reports whether sampling is skipped because all sinks are idle.
+func (p *Poller) Idle() bool {
+	for _, sink := range p.sinks {
+		if is, ok := sink.(IdleSink); !ok || !is.Idle() {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *Poller) poll(ctx context.Context) {
+	if p.Idle() {
+		return
+	}
+
+	s, err := p.sample()
+	if err != nil {
+		p.log.Warn().Err(err).Msg("sampling failed")
+	}
+
+	for _, sink := range p.sinks {
+		if as, ok := sink.(AvailabilitySink); ok {
+			if err := as.Availability(ctx, err == nil && p.rg.IsConnected()); err != nil {
+				p.log.Warn().Err(err).Msg("unable to report availability")
+			}
+		}
+		if err != nil {
+			continue
+		}
+		if err := sink.Write(ctx, s); err != nil {
+			p.log.Warn().Err(err).Msg("unable to write sample")
+		}
+	}
+}
+
+func (p *Poller) sample() (redgiant.Snapshot, error) {
+	p.log.Trace().Msg("Poller.sample()")
+
+	// Services that cannot be read are logged by Snapshot and do not fail the
+	// pass.
+	return p.rg.Snapshot(p.lang)
+}
+
+func (p *Poller) Close() error {
+	var errs []error
+	for _, sink := range p.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
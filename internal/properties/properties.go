@@ -0,0 +1,301 @@
+// Package properties parses the Java .properties format the inverter ships
+// its translations in, as specified by java.util.Properties.load.
+package properties
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+type SyntaxError struct {
+	// Line is the number of the natural line the malformed logical line
+	// starts on.
+	Line int
+	Msg  string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// Parse reads all key-value pairs from r. Later occurrences of a key override
+// earlier ones. Unlike Java, the input is read as UTF-8 rather than ISO 8859-1.
+func Parse(r io.Reader) (map[string]string, error) {
+	m := map[string]string{}
+	err := Scan(r, func(key, value string) {
+		m[key] = value
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Scan calls fn for every key-value pair in r in order of appearance.
+func Scan(r io.Reader, fn func(key, value string)) error {
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	s.Split(scanLines)
+
+	var (
+		logical []byte
+		start   int
+		n       int
+	)
+	for s.Scan() {
+		n++
+		line := s.Bytes()
+		if n == 1 {
+			line = bytes.TrimPrefix(line, []byte("\uFEFF"))
+		}
+
+		if logical == nil {
+			line = trimLeadingSpace(line)
+			if len(line) == 0 || line[0] == '#' || line[0] == '!' {
+				continue
+			}
+			start = n
+			logical = []byte{}
+		} else {
+			// leading whitespace of continuation lines is discarded
+			line = trimLeadingSpace(line)
+		}
+
+		if continues(line) {
+			logical = append(logical, line[:len(line)-1]...)
+			continue
+		}
+		logical = append(logical, line...)
+
+		key, value, err := parseLine(logical)
+		if err != nil {
+			return &SyntaxError{Line: start, Msg: err.Error()}
+		}
+		fn(key, value)
+		logical = nil
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+
+	// a continuation at the end of the input is dropped, like Java does
+	if logical != nil {
+		key, value, err := parseLine(logical)
+		if err != nil {
+			return &SyntaxError{Line: start, Msg: err.Error()}
+		}
+		fn(key, value)
+	}
+	return nil
+}
+
+// scanLines splits on \n, \r and \r\n, which all terminate a line.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\n' {
+			return i + 1, data[:i], nil
+		}
+		if i+1 < len(data) {
+			if data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+			return i + 1, data[:i], nil
+		}
+		if atEOF {
+			return i + 1, data[:i], nil
+		}
+		// the next chunk might start with \n
+		return 0, nil, nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\f'
+}
+
+func trimLeadingSpace(line []byte) []byte {
+	i := 0
+	for i < len(line) && isSpace(line[i]) {
+		i++
+	}
+	return line[i:]
+}
+
+// continues reports whether the line ends in an odd number of backslashes.
+func continues(line []byte) bool {
+	n := 0
+	for i := len(line) - 1; i >= 0 && line[i] == '\\'; i-- {
+		n++
+	}
+	return n%2 == 1
+}
+
+func parseLine(line []byte) (string, string, error) {
+	// the key ends at the first unescaped separator or whitespace
+	end := len(line)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if c == '\\' {
+			i++
+			continue
+		}
+		if c == '=' || c == ':' || isSpace(c) {
+			end = i
+			break
+		}
+	}
+
+	rest := trimLeadingSpace(line[end:])
+	if len(rest) > 0 && (rest[0] == '=' || rest[0] == ':') {
+		rest = trimLeadingSpace(rest[1:])
+	}
+
+	key, err := unescape(line[:end])
+	if err != nil {
+		return "", "", err
+	}
+	value, err := unescape(rest)
+	if err != nil {
+		return "", "", err
+	}
+	return key, value, nil
+}
+
+func unescape(b []byte) (string, error) {
+	if bytes.IndexByte(b, '\\') < 0 {
+		return string(b), nil
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(b))
+	var units []uint16
+	flush := func() {
+		if len(units) > 0 {
+			sb.WriteString(string(utf16.Decode(units)))
+			units = units[:0]
+		}
+	}
+
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		if c != '\\' {
+			flush()
+			sb.WriteByte(c)
+			continue
+		}
+
+		i++
+		if i == len(b) {
+			break
+		}
+		if b[i] == 'u' {
+			if i+5 > len(b) {
+				return "", fmt.Errorf("malformed \\uxxxx escape %q", b[i-1:])
+			}
+			u, ok := parseHex(b[i+1 : i+5])
+			if !ok {
+				return "", fmt.Errorf("malformed \\uxxxx escape %q", b[i-1:i+5])
+			}
+			// surrogate pairs are spread across two escapes
+			units = append(units, u)
+			i += 4
+			continue
+		}
+
+		flush()
+		switch b[i] {
+		case 't':
+			sb.WriteByte('\t')
+		case 'n':
+			sb.WriteByte('\n')
+		case 'r':
+			sb.WriteByte('\r')
+		case 'f':
+			sb.WriteByte('\f')
+		default:
+			// any other escaped character stands for itself
+			_, size := utf8.DecodeRune(b[i:])
+			sb.Write(b[i : i+size])
+			i += size - 1
+		}
+	}
+	flush()
+	return sb.String(), nil
+}
+
+func parseHex(b []byte) (uint16, bool) {
+	var v uint16
+	for _, c := range b {
+		v <<= 4
+		switch {
+		case '0' <= c && c <= '9':
+			v |= uint16(c - '0')
+		case 'a' <= c && c <= 'f':
+			v |= uint16(c - 'a' + 10)
+		case 'A' <= c && c <= 'F':
+			v |= uint16(c - 'A' + 10)
+		default:
+			return 0, false
+		}
+	}
+	return v, true
+}
+
+// Write writes the key-value pairs to w sorted by key. Non-ASCII characters
+// are written as UTF-8 rather than escaped, except for a byte order mark
+// leading the first key, which Parse would strip.
+func Write(w io.Writer, m map[string]string) error {
+	bw := bufio.NewWriter(w)
+	for i, key := range slices.Sorted(maps.Keys(m)) {
+		k := key
+		if rest, ok := strings.CutPrefix(key, "\uFEFF"); ok && i == 0 {
+			bw.WriteString(`\uFEFF`)
+			k = rest
+		}
+		bw.WriteString(escape(k, true))
+		bw.WriteByte('=')
+		bw.WriteString(escape(m[key], false))
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+func escape(s string, key bool) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == '\\' || r == '=' || r == ':' || r == '#' || r == '!':
+			sb.WriteString(`\` + string(r))
+		case r == ' ' && (key || i == 0):
+			sb.WriteString(`\ `)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\f':
+			sb.WriteString(`\f`)
+		default:
+			// invalid UTF-8 is passed through as is
+			sb.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return sb.String()
+}
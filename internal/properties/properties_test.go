@@ -0,0 +1,117 @@
+package properties
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected map[string]string
+	}{
+		{name: "equals", input: "a=b", expected: map[string]string{"a": "b"}},
+		{name: "colon", input: "a:b", expected: map[string]string{"a": "b"}},
+		{name: "whitespace separator", input: "a b", expected: map[string]string{"a": "b"}},
+		{name: "whitespace around separator", input: "  a \t= \fb c ", expected: map[string]string{"a": "b c "}},
+		{name: "empty value", input: "a=\nb", expected: map[string]string{"a": "", "b": ""}},
+		{name: "separator in value", input: "a==b:c", expected: map[string]string{"a": "=b:c"}},
+		{name: "comments and blank lines", input: "# x=y\n\n  ! x=y\n\t\na=b", expected: map[string]string{"a": "b"}},
+		{name: "line endings", input: "a=1\rb=2\r\nc=3\n", expected: map[string]string{"a": "1", "b": "2", "c": "3"}},
+		{name: "continuation", input: "a=b\\\n    c\\\n\td", expected: map[string]string{"a": "bcd"}},
+		{name: "continuation looks like comment", input: "a=b\\\n#c", expected: map[string]string{"a": "b#c"}},
+		{name: "escaped backslash", input: "a=b\\\\\nc=d", expected: map[string]string{"a": "b\\", "c": "d"}},
+		{name: "continuation at end", input: "a=b\\", expected: map[string]string{"a": "b"}},
+		{name: "escaped separator in key", input: "a\\=b\\ c=d", expected: map[string]string{"a=b c": "d"}},
+		{name: "escapes", input: `a=\t\n\r\f\x\"\ü`, expected: map[string]string{"a": "\t\n\r\f" + `x"ü`}},
+		{name: "unicode escape", input: `a=Batteriest\u00E4nde`, expected: map[string]string{"a": "Batteriestände"}},
+		{name: "surrogate pair", input: `a=\uD83D\uDD0B`, expected: map[string]string{"a": "\U0001F50B"}},
+		{name: "utf-8", input: "a=电池", expected: map[string]string{"a": "电池"}},
+		{name: "byte order mark", input: "\uFEFFa=b", expected: map[string]string{"a": "b"}},
+		{name: "duplicate key", input: "a=b\na=c", expected: map[string]string{"a": "c"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Parse(strings.NewReader(test.input))
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestParseMalformedUnicodeEscape(t *testing.T) {
+	for _, input := range []string{`a=\u00`, `a=\u00G0`, "b=c\n\nd=\\\n  \\uXYZW"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(input))
+			var serr *SyntaxError
+			require.ErrorAs(t, err, &serr)
+		})
+	}
+
+	_, err := Parse(strings.NewReader("b=c\n\nd=\\\n  \\uXYZW"))
+	assert.EqualError(t, err, `line 3: malformed \uxxxx escape "\\uXYZW"`)
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"a=b",
+		"# comment\n! comment\n\na : b\\\n  c",
+		`a\ b=ä🔋\t`,
+		"a=b\r\nc=d\re=f\\",
+		`\uFEFFa=b`,
+	} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, input string) {
+		m, err := Parse(strings.NewReader(input))
+		if err != nil {
+			return
+		}
+		// parsing the written pairs yields the same pairs
+		var sb strings.Builder
+		require.NoError(t, Write(&sb, m))
+		actual, err := Parse(strings.NewReader(sb.String()))
+		require.NoError(t, err)
+		assert.Equal(t, m, actual)
+	})
+}
+
+func TestWrite(t *testing.T) {
+	var sb strings.Builder
+	err := Write(&sb, map[string]string{
+		"b":   " leading space",
+		"a":   "x=y:z #!",
+		"c d": "line\nbreak\\",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "a=x\\=y\\:z \\#\\!\nb=\\ leading space\nc\\ d=line\\nbreak\\\\\n", sb.String())
+}
+
+func TestWriteByteOrderMark(t *testing.T) {
+	tests := []struct {
+		name     string
+		m        map[string]string
+		expected string
+	}{
+		{name: "first key", m: map[string]string{"\uFEFFa": "b"}, expected: "\\uFEFFa=b\n"},
+		{name: "other keys", m: map[string]string{"a": "\uFEFFb", "\uFEFFc": "d"}, expected: "a=\uFEFFb\n\uFEFFc=d\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var sb strings.Builder
+			require.NoError(t, Write(&sb, test.m))
+			assert.Equal(t, test.expected, sb.String())
+
+			actual, err := Parse(strings.NewReader(sb.String()))
+			require.NoError(t, err)
+			assert.Equal(t, test.m, actual)
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package serve
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pmeier/redgiant/internal/errors"
+)
+
+func adminRouteFuncs() []routeFunc {
+	return []routeFunc{releaseRouteFunc, reclaimRouteFunc}
+}
+
+type releaseResponse struct {
+	ReleasedUntil time.Time `json:"releasedUntil"`
+}
+
+func releaseRouteFunc(s *Server) (string, string, echo.HandlerFunc) {
+	return http.MethodPost, "/release", func(c echo.Context) error {
+		// echo only binds query parameters for GET, HEAD and DELETE requests
+		minutes := 10
+		if err := echo.QueryParamsBinder(c).Int("minutes", &minutes).BindError(); err != nil {
+			return err
+		}
+		if minutes < 1 || minutes > 24*60 {
+			return errors.New(
+				"minutes out of range",
+				errors.WithCode(errors.InvalidParameterCode),
+				errors.WithContext(errors.Context{"minutes": minutes, "min": 1, "max": 24 * 60}),
+				errors.WithHTTPCode(http.StatusUnprocessableEntity),
+				errors.WithFault(errors.ClientFault),
+				errors.WithHTTPDetail(errors.ContextHTTPDetail),
+			)
+		}
+
+		until := s.rg.Release(time.Duration(minutes) * time.Minute)
+		return c.JSON(http.StatusOK, releaseResponse{ReleasedUntil: until})
+	}
+}
+
+func reclaimRouteFunc(s *Server) (string, string, echo.HandlerFunc) {
+	return http.MethodDelete, "/release", func(c echo.Context) error {
+		s.rg.Release(0)
+		return c.NoContent(http.StatusNoContent)
+	}
+}
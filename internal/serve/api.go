@@ -1,25 +1,35 @@
 package serve
 
 import (
+	"math"
 	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/errors"
+	"golang.org/x/text/language"
 )
 
-func getRouteFunc[P any, O any](path string, bindFunc func(echo.Context) (P, error), outputFunc func(*redgiant.Redgiant, P) (O, error)) routeFunc {
+func getRouteFunc[P any, O any](path string, bindFunc func(*redgiant.Redgiant, echo.Context) (P, error), outputFunc func(*redgiant.Redgiant, P) (O, error)) routeFunc {
 	return func(s *Server) (string, string, echo.HandlerFunc) {
 		return http.MethodGet, path, func(c echo.Context) error {
-			p, err := bindFunc(c)
+			p, err := bindFunc(s.rg, c)
 			if err != nil {
 				return err
 			}
 
 			o, err := outputFunc(s.rg, p)
-			switch err.(type) {
-			case *redgiant.SungrowDisconnectedError:
-				panic(err.Error())
-			case error:
+			var de *redgiant.SungrowDisconnectedError
+			var ie *redgiant.InverterError
+			if errors.As(err, &de) {
+				return newInverterUnavailableError(s.rg.ConnectionState())
+			} else if errors.As(err, &ie) {
+				return newInverterResultError(ie)
+			} else if err != nil {
 				return err
 			}
 
@@ -28,10 +38,60 @@ func getRouteFunc[P any, O any](path string, bindFunc func(echo.Context) (P, err
 	}
 }
 
+// newInverterUnavailableError tells clients to come back once the next
+// connection attempt was made.
+func newInverterUnavailableError(state redgiant.ConnectionState) error {
+	retryAfter := 5
+	if !state.NextAttempt.IsZero() {
+		retryAfter = max(int(math.Ceil(time.Until(state.NextAttempt).Seconds())), 1)
+	}
+
+	ctx := errors.Context{"retryAfter": retryAfter}
+	if state.LastError != "" {
+		ctx["reason"] = state.LastError
+	}
+	return errors.New(
+		"inverter disconnected",
+		errors.WithCode(errors.InverterDisconnectedCode),
+		errors.WithContext(ctx),
+		errors.WithHTTPCode(http.StatusServiceUnavailable),
+		errors.WithHTTPDetail(errors.ContextHTTPDetail),
+		errors.WithHTTPHeader(echo.HeaderRetryAfter, strconv.Itoa(retryAfter)),
+	)
+}
+
+// resultPolicyHTTPCodes maps how a result code of the inverter is handled to
+// the status of the response. Result codes that were not resolved by
+// reconnecting or retrying are expected to go away, so clients may retry.
+var resultPolicyHTTPCodes = map[redgiant.ResultPolicy]int{
+	redgiant.FailResultPolicy:      http.StatusBadGateway,
+	redgiant.ReconnectResultPolicy: http.StatusServiceUnavailable,
+	redgiant.RetryResultPolicy:     http.StatusServiceUnavailable,
+}
+
+// inverterRetryAfter is the time clients are asked to wait after a transient
+// result code.
+const inverterRetryAfter = 5
+
+func newInverterResultError(ie *redgiant.InverterError) error {
+	code, ok := resultPolicyHTTPCodes[ie.Policy()]
+	if !ok {
+		code = http.StatusBadGateway
+	}
+	opts := []errors.OptFunc{errors.WithHTTPCode(code)}
+	if code == http.StatusServiceUnavailable {
+		opts = append(opts, errors.WithHTTPHeader(echo.HeaderRetryAfter, strconv.Itoa(inverterRetryAfter)))
+	}
+	if meaning := ie.Meaning(); meaning != "" {
+		opts = append(opts, errors.WithContext(errors.Context{"meaning": meaning}))
+	}
+	return errors.Wrap(ie, opts...)
+}
+
 func noInputRouteFunc[T any](path string, noInputFunc func(*redgiant.Redgiant) (T, error)) routeFunc {
 	type Params struct{}
 
-	bindFunc := func(c echo.Context) (Params, error) {
+	bindFunc := func(_ *redgiant.Redgiant, c echo.Context) (Params, error) {
 		var p Params
 		if err := c.Bind(&p); err != nil {
 			return Params{}, err
@@ -53,11 +113,12 @@ func dataRouteFunc[T any](path string, dataFunc func(*redgiant.Redgiant, int, re
 		Services []string          `query:"service"`
 	}
 
-	bindFunc := func(c echo.Context) (Params, error) {
+	bindFunc := func(rg *redgiant.Redgiant, c echo.Context) (Params, error) {
 		var p Params
 		if err := c.Bind(&p); err != nil {
 			return Params{}, err
 		}
+		p.Language = negotiateLanguage(rg, c, p.Language)
 		return p, nil
 	}
 
@@ -69,6 +130,215 @@ func dataRouteFunc[T any](path string, dataFunc func(*redgiant.Redgiant, int, re
 
 }
 
+func snapshotRouteFunc() routeFunc {
+	type Params struct {
+		Language redgiant.Language `query:"lang"`
+	}
+
+	bindFunc := func(rg *redgiant.Redgiant, c echo.Context) (Params, error) {
+		var p Params
+		if err := c.Bind(&p); err != nil {
+			return Params{}, err
+		}
+		p.Language = negotiateLanguage(rg, c, p.Language)
+		return p, nil
+	}
+
+	outputFunc := func(rg *redgiant.Redgiant, p Params) (redgiant.Snapshot, error) {
+		return rg.Snapshot(p.Language)
+	}
+
+	return getRouteFunc("/snapshot", bindFunc, outputFunc)
+}
+
+func energyFlowRouteFunc() routeFunc {
+	type Params struct {
+		DeviceID int `query:"device"`
+	}
+
+	bindFunc := func(_ *redgiant.Redgiant, c echo.Context) (Params, error) {
+		var p Params
+		if err := c.Bind(&p); err != nil {
+			return Params{}, err
+		}
+		return p, nil
+	}
+
+	outputFunc := func(rg *redgiant.Redgiant, p Params) (redgiant.EnergyFlow, error) {
+		return rg.EnergyFlow(p.DeviceID)
+	}
+
+	return getRouteFunc("/energyflow", bindFunc, outputFunc)
+}
+
+// negotiateLanguage resolves the requested language to one the inverter ships.
+// Without an explicit language, it is negotiated from the Accept-Language
+// header. Without either, names are not localized.
+func negotiateLanguage(rg *redgiant.Redgiant, c echo.Context, lang redgiant.Language) redgiant.Language {
+	c.Response().Header().Add(echo.HeaderVary, "Accept-Language")
+
+	var preferred []language.Tag
+	if lang != redgiant.NoLanguage {
+		preferred = []language.Tag{lang.Tag()}
+	} else if h := c.Request().Header.Get("Accept-Language"); h != "" {
+		// malformed headers are ignored rather than rejected
+		preferred, _, _ = language.ParseAcceptLanguage(h)
+	}
+	if len(preferred) == 0 {
+		return redgiant.NoLanguage
+	}
+
+	lang = rg.MatchLanguage(preferred...)
+	c.Response().Header().Set("Content-Language", lang.Tag().String())
+	return lang
+}
+
+func languagesRouteFunc(s *Server) (string, string, echo.HandlerFunc) {
+	return http.MethodGet, "/languages", func(c echo.Context) error {
+		return c.JSON(http.StatusOK, s.rg.Languages())
+	}
+}
+
+type searchParams struct {
+	Query     string              `query:"q"`
+	Languages []redgiant.Language `query:"lang"`
+	Limit     int                 `query:"limit"`
+}
+
+type searchResult struct {
+	redgiant.SearchResult
+	// Devices are the IDs of the devices reporting the code. It is omitted if
+	// the inverter could not be queried.
+	Devices []int `json:"devices,omitempty"`
+}
+
+const defaultSearchLimit = 20
+
+func searchRouteFunc() routeFunc {
+	var devices reportingDevicesCache
+
+	return func(s *Server) (string, string, echo.HandlerFunc) {
+		bindFunc := func(rg *redgiant.Redgiant, c echo.Context) (searchParams, error) {
+			p := searchParams{Limit: defaultSearchLimit}
+			if err := c.Bind(&p); err != nil {
+				return searchParams{}, err
+			}
+			if p.Query == "" {
+				return searchParams{}, errors.New(
+					"missing query",
+					errors.WithCode(errors.InvalidParameterCode),
+					errors.WithHTTPCode(http.StatusUnprocessableEntity),
+					errors.WithFault(errors.ClientFault),
+					errors.WithHTTPDetail(errors.MessageHTTPDetail),
+				)
+			}
+			if p.Limit < 1 {
+				return searchParams{}, errors.New(
+					"limit must be positive",
+					errors.WithCode(errors.InvalidParameterCode),
+					errors.WithContext(errors.Context{"limit": p.Limit}),
+					errors.WithHTTPCode(http.StatusUnprocessableEntity),
+					errors.WithFault(errors.ClientFault),
+					errors.WithHTTPDetail(errors.ContextHTTPDetail),
+				)
+			}
+
+			// searching all languages downloads the ones not loaded yet
+			if len(p.Languages) == 0 {
+				p.Languages = s.languages
+			}
+			langs := make([]redgiant.Language, 0, len(p.Languages))
+			for _, lang := range p.Languages {
+				if lang := rg.MatchLanguage(lang.Tag()); !slices.Contains(langs, lang) {
+					langs = append(langs, lang)
+				}
+			}
+			p.Languages = langs
+			return p, nil
+		}
+
+		outputFunc := func(rg *redgiant.Redgiant, p searchParams) ([]searchResult, error) {
+			rs, err := rg.Search(p.Query, p.Languages...)
+			if err != nil {
+				return nil, err
+			}
+			if len(rs) > p.Limit {
+				rs = rs[:p.Limit]
+			}
+
+			reporting := devices.get(rg)
+			results := make([]searchResult, 0, len(rs))
+			for _, r := range rs {
+				results = append(results, searchResult{SearchResult: r, Devices: reporting[r.I18NCode]})
+			}
+			return results, nil
+		}
+
+		return getRouteFunc("/search", bindFunc, outputFunc)(s)
+	}
+}
+
+// reportingDevicesTTL is how long the devices reporting the i18n codes are
+// cached. Reading them takes a request per device and service, but the
+// measurements a device reports hardly ever change.
+const reportingDevicesTTL = 15 * time.Minute
+
+// reportingDevicesCache keeps the search from reading all devices on every
+// request.
+type reportingDevicesCache struct {
+	mu      sync.Mutex
+	devices map[string][]int
+	expires time.Time
+}
+
+// get returns the cached devices or reads them if they expired. Since the
+// search is useful without them, failures are ignored and the expired devices
+// are kept.
+func (c *reportingDevicesCache) get(rg *redgiant.Redgiant) map[string][]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.devices != nil && time.Now().Before(c.expires) {
+		return c.devices
+	}
+	devices, err := reportingDevices(rg)
+	if err != nil {
+		return c.devices
+	}
+	c.devices = devices
+	c.expires = time.Now().Add(reportingDevicesTTL)
+	return c.devices
+}
+
+// reportingDevices maps i18n codes to the devices reporting them.
+func reportingDevices(rg *redgiant.Redgiant) (map[string][]int, error) {
+	devices := map[string][]int{}
+	ds, err := rg.Devices()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, d := range ds {
+		var codes []string
+		if ms, err := rg.RealData(d.ID, redgiant.NoLanguage); err == nil {
+			for _, m := range ms {
+				codes = append(codes, m.I18NCode)
+			}
+		}
+		if ms, err := rg.DirectData(d.ID, redgiant.NoLanguage); err == nil {
+			for _, m := range ms {
+				codes = append(codes, m.I18NCode)
+			}
+		}
+
+		slices.Sort(codes)
+		for _, code := range slices.Compact(codes) {
+			devices[code] = append(devices[code], d.ID)
+		}
+	}
+	return devices, nil
+}
+
 func apiRouteFuncs() []routeFunc {
 	return []routeFunc{
 		noInputRouteFunc("/about", (*redgiant.Redgiant).About),
@@ -76,5 +346,9 @@ func apiRouteFuncs() []routeFunc {
 		noInputRouteFunc("/devices", (*redgiant.Redgiant).Devices),
 		dataRouteFunc("/data/:deviceID/real", (*redgiant.Redgiant).RealData),
 		dataRouteFunc("/data/:deviceID/direct", (*redgiant.Redgiant).DirectData),
+		snapshotRouteFunc(),
+		energyFlowRouteFunc(),
+		languagesRouteFunc,
+		searchRouteFunc(),
 	}
 }
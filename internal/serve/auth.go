@@ -0,0 +1,65 @@
+package serve
+
+import (
+	"os"
+
+	"github.com/pmeier/redgiant/internal/auth"
+	"github.com/pmeier/redgiant/internal/config"
+)
+
+func toScopes(ss []string) []auth.Scope {
+	scopes := make([]auth.Scope, 0, len(ss))
+	for _, s := range ss {
+		scopes = append(scopes, auth.Scope(s))
+	}
+	return scopes
+}
+
+func newAuthorizer(sc config.ServerConfig) (*auth.Authorizer, error) {
+	c := sc.Auth
+	var as []auth.Authenticator
+
+	if sc.TLS.Enabled && sc.TLS.ClientAuth != config.NoClientAuth {
+		as = append(as, auth.NewClientCertAuthenticator(toScopes(sc.TLS.ClientCertScopes)...))
+	}
+
+	if len(c.APIKeys) > 0 {
+		keys := make([]auth.APIKey, 0, len(c.APIKeys))
+		for _, k := range c.APIKeys {
+			keys = append(keys, auth.APIKey{Key: k.Key, Name: k.Name, Scopes: toScopes(k.Scopes)})
+		}
+		as = append(as, auth.NewAPIKeyAuthenticator(keys...))
+	}
+
+	if c.JWT.Secret != "" || c.JWT.PublicKeyFile != "" {
+		opts := auth.JWTOptions{
+			Secret:     []byte(c.JWT.Secret),
+			Issuer:     c.JWT.Issuer,
+			Audience:   c.JWT.Audience,
+			ScopeClaim: c.JWT.ScopeClaim,
+			Leeway:     c.JWT.Leeway,
+		}
+		if c.JWT.PublicKeyFile != "" {
+			b, err := os.ReadFile(c.JWT.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			opts.PublicKey = b
+		}
+		a, err := auth.NewJWTAuthenticator(opts)
+		if err != nil {
+			return nil, err
+		}
+		as = append(as, a)
+	}
+
+	if len(c.Basic) > 0 {
+		users := make([]auth.BasicUser, 0, len(c.Basic))
+		for _, u := range c.Basic {
+			users = append(users, auth.BasicUser{Username: u.Username, PasswordHash: u.PasswordHash, Scopes: toScopes(u.Scopes)})
+		}
+		as = append(as, auth.NewBasicAuthenticator("redgiant", users...))
+	}
+
+	return auth.NewAuthorizer(as...), nil
+}
@@ -0,0 +1,56 @@
+package serve
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pmeier/redgiant/internal/history"
+)
+
+type durationParam time.Duration
+
+func (d *durationParam) UnmarshalParam(param string) error {
+	v, err := time.ParseDuration(param)
+	if err != nil {
+		return err
+	}
+	*d = durationParam(v)
+	return nil
+}
+
+func historyRouteFunc(s *Server) (string, string, echo.HandlerFunc) {
+	type Params struct {
+		DeviceID int           `param:"deviceID"`
+		Metrics  []string      `query:"metric"`
+		From     time.Time     `query:"from"`
+		To       time.Time     `query:"to"`
+		Step     durationParam `query:"step"`
+	}
+
+	return http.MethodGet, "/history/:deviceID", func(c echo.Context) error {
+		var p Params
+		if err := c.Bind(&p); err != nil {
+			return err
+		}
+		if p.To.IsZero() {
+			p.To = time.Now()
+		}
+		if p.From.IsZero() {
+			p.From = p.To.Add(-24 * time.Hour)
+		}
+
+		series, err := s.history.Query(history.Query{
+			DeviceID: p.DeviceID,
+			Metrics:  p.Metrics,
+			From:     p.From,
+			To:       p.To,
+			Step:     time.Duration(p.Step),
+		})
+		if err != nil {
+			return err
+		}
+
+		return c.JSON(http.StatusOK, series)
+	}
+}
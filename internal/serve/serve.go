@@ -1,10 +1,21 @@
 package serve
 
 import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
 	"time"
 
 	"github.com/pmeier/redgiant"
 	"github.com/pmeier/redgiant/internal/config"
+	"github.com/pmeier/redgiant/internal/health"
+	"github.com/pmeier/redgiant/internal/history"
+	"github.com/pmeier/redgiant/internal/influx"
+	"github.com/pmeier/redgiant/internal/mqtt"
+	"github.com/pmeier/redgiant/internal/poll"
 
 	"github.com/rs/zerolog"
 )
@@ -12,6 +23,9 @@ import (
 func Run(c config.Config) error {
 	logger := zerolog.New(c.Logging.Format.Writer()).With().Timestamp().Logger().Level(c.Logging.Level)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	sg := redgiant.NewSungrow(
 		c.Sungrow.Host,
 		c.Sungrow.Username,
@@ -19,19 +33,206 @@ func Run(c config.Config) error {
 		redgiant.WithLogger(logger),
 		redgiant.WithReconnect(c.Sungrow.ReconnectTries),
 	)
-	rg := redgiant.NewRedgiant(sg, redgiant.WithLogger(logger))
+	var overrides map[redgiant.Language]map[string]string
+	if c.I18N.OverrideDir != "" {
+		var err error
+		if overrides, err = redgiant.LoadOverrides(c.I18N.OverrideDir); err != nil {
+			return err
+		}
+	}
+	rg := redgiant.NewRedgiant(
+		sg,
+		redgiant.WithLogger(logger),
+		redgiant.WithConnectionMode(c.Sungrow.ConnectionMode, c.Sungrow.IdleTimeout),
+		redgiant.WithCacheDir(c.I18N.CacheDir),
+		redgiant.WithTranslationOverrides(overrides),
+	)
+
+	langs := preloadLanguages(c)
+	go preload(rg, langs, logger)
 
-	if err := rg.Connect(); err != nil {
+	// The inverter might be asleep or rebooting, so the server starts regardless
+	// and requests are answered with 503 until the connection is established.
+	defer rg.Close()
+	connectCtx, cancelConnect := context.WithCancel(ctx)
+	defer cancelConnect()
+	go rg.KeepConnected(connectCtx, c.Sungrow.MinConnectBackoff, c.Sungrow.MaxConnectBackoff)
+
+	sinks, err := newSinks(c, logger)
+	if err != nil {
 		return err
 	}
-	defer rg.Close()
 
-	s := newServer(rg, logger)
-	if err := s.Start(c.Server.Host, c.Server.Port, 5*time.Second); err != nil {
+	var hs *history.Store
+	if c.History.Enabled {
+		hs, err = history.Open(
+			c.History.Directory,
+			history.DefaultTiers(
+				c.History.RawRetention,
+				c.History.FiveMinuteRetention,
+				c.History.HourlyRetention,
+				c.History.DailyRetention,
+			),
+			logger.With().Str("sink", "history").Logger(),
+		)
+		if err != nil {
+			return err
+		}
+		sinks = append(sinks, hs)
+	}
+
+	bc := newBroadcaster()
+	sinks = append(sinks, bc)
+
+	p := poll.New(rg, c.Poll.Interval, c.Poll.Language, logger, sinks...)
+	defer p.Close()
+
+	az, err := newAuthorizer(c.Server)
+	if err != nil {
+		return err
+	}
+
+	ro := health.ReadinessOptions{
+		MaxHeartbeatAge: c.Health.MaxHeartbeatAge,
+		MaxReadAge:      maxReadAge(c),
+		Polling:         func() bool { return !p.Idle() },
+	}
+	s := newServer(rg, hs, bc, az, ro, langs, c.Server.ErrorFormat, logger)
+	if err := s.Start(c.Server, 5*time.Second); err != nil {
 		return err
 	}
 
-	select {}
+	pollCtx, cancelPoll := context.WithCancel(ctx)
+	defer cancelPoll()
+	pollDone := make(chan struct{})
+	go func() {
+		defer close(pollDone)
+		if len(sinks) > 0 {
+			p.Run(pollCtx)
+		}
+	}()
+
+	select {
+	case err := <-s.Done():
+		if err == nil {
+			err = errors.New("server stopped unexpectedly")
+		}
+		return err
+	case <-ctx.Done():
+	}
+	// restore the default behavior, so a second signal terminates immediately
+	stop()
+
+	logger.Info().Dur("timeout", c.Server.ShutdownTimeout).Msg("shutting down")
+	return shutdown(s, cancelPoll, pollDone, c.Server.ShutdownTimeout)
+}
+
+// preloadLanguages are the configured languages and the language of the
+// poller.
+func preloadLanguages(c config.Config) []redgiant.Language {
+	langs := slices.Clone(c.I18N.Preload)
+	if c.Poll.Language != redgiant.NoLanguage && !slices.Contains(langs, c.Poll.Language) {
+		langs = append(langs, c.Poll.Language)
+	}
+	return langs
+}
+
+// preload loads the languages in the background, since the inverter might be
+// unreachable at startup.
+func preload(rg *redgiant.Redgiant, langs []redgiant.Language, logger zerolog.Logger) {
+	if len(langs) == 0 {
+		return
+	}
+
+	start := time.Now()
+	if err := rg.Preload(langs...); err != nil {
+		logger.Warn().Err(err).Msg("unable to preload all languages")
+		return
+	}
+	logger.Info().Interface("languages", langs).Dur("took", time.Since(start)).Msg("preloaded languages")
+}
+
+// shutdown drains in-flight requests and waits for the poller to finish its
+// current sampling pass. Closing the sinks and the connection to the inverter
+// is left to the caller.
+func shutdown(s *Server, cancelPoll context.CancelFunc, pollDone <-chan struct{}, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	if err := s.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	cancelPoll()
+	select {
+	case <-pollDone:
+	case <-ctx.Done():
+		errs = append(errs, errors.New("poller did not stop in time"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func newSinks(c config.Config, logger zerolog.Logger) ([]poll.Sink, error) {
+	var sinks []poll.Sink
+
+	if c.MQTT.Enabled {
+		sinks = append(sinks, mqtt.NewSink(
+			mqtt.ClientOptions{
+				Broker:    c.MQTT.Broker,
+				ClientID:  c.MQTT.ClientID,
+				Username:  c.MQTT.Username,
+				Password:  c.MQTT.Password,
+				KeepAlive: c.MQTT.KeepAlive,
+			},
+			mqtt.SinkOptions{
+				TopicPrefix:       c.MQTT.TopicPrefix,
+				Discovery:         c.MQTT.Discovery,
+				DiscoveryPrefix:   c.MQTT.DiscoveryPrefix,
+				QoS:               c.MQTT.QoS,
+				Retain:            c.MQTT.Retain,
+				ReconnectInterval: c.MQTT.ReconnectInterval,
+			},
+			logger.With().Str("sink", "mqtt").Logger(),
+		))
+	}
 
-	return nil
+	if c.Influx.Enabled {
+		w, err := influx.NewWriter(influx.WriterOptions{
+			URL:     c.Influx.URL,
+			Token:   c.Influx.Token,
+			Org:     c.Influx.Org,
+			Bucket:  c.Influx.Bucket,
+			Timeout: c.Influx.Timeout,
+		})
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, influx.NewSink(
+			w,
+			influx.SinkOptions{
+				Tags:          c.Influx.Tags,
+				ExtraTags:     c.Influx.ExtraTags,
+				BatchSize:     c.Influx.BatchSize,
+				FlushInterval: c.Influx.FlushInterval,
+				BufferSize:    c.Influx.BufferSize,
+			},
+			logger.With().Str("sink", "influx").Logger(),
+		))
+	}
+
+	return sinks, nil
+}
+
+// maxReadAge resolves the default of the read check of the readiness, which
+// allows for a few failed polls.
+func maxReadAge(c config.Config) time.Duration {
+	switch {
+	case c.Health.MaxReadAge < 0:
+		return 0
+	case c.Health.MaxReadAge == 0:
+		return 3 * c.Poll.Interval
+	}
+	return c.Health.MaxReadAge
 }
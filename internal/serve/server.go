@@ -2,21 +2,34 @@ package serve
 
 import (
 	"embed"
-	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/auth"
+	"github.com/pmeier/redgiant/internal/certs"
+	"github.com/pmeier/redgiant/internal/config"
 	"github.com/pmeier/redgiant/internal/errors"
 	"github.com/pmeier/redgiant/internal/health"
+	"github.com/pmeier/redgiant/internal/history"
 	"github.com/rs/zerolog"
 )
 
 type Server struct {
 	*echo.Echo
-	rg  *redgiant.Redgiant
-	log zerolog.Logger
+	rg      *redgiant.Redgiant
+	history *history.Store
+	stream  *broadcaster
+	auth    *auth.Authorizer
+	// languages are searched if a search does not ask for any.
+	languages []redgiant.Language
+	log       zerolog.Logger
+	done      chan error
 }
 
 type routeFunc = func(*Server) (string, string, echo.HandlerFunc)
@@ -24,18 +37,35 @@ type routeFunc = func(*Server) (string, string, echo.HandlerFunc)
 //go:embed static/*
 var staticFS embed.FS
 
-func newServer(rg *redgiant.Redgiant, logger zerolog.Logger) *Server {
+func newServer(rg *redgiant.Redgiant, hs *history.Store, bc *broadcaster, az *auth.Authorizer, ro health.ReadinessOptions, langs []redgiant.Language, ef errors.Format, logger zerolog.Logger) *Server {
 	e := echo.New()
 	e.HideBanner = true
 	e.HidePort = true
 	e.Debug = true
 
-	s := &Server{Echo: e, rg: rg, log: logger}
+	s := &Server{Echo: e, rg: rg, history: hs, stream: bc, auth: az, languages: langs, log: logger, done: make(chan error, 1)}
 
 	routeFuncs := []routeFunc{
-		wrapBasicRouteFunc(health.HealthRouteFunc),
+		func(s *Server) (string, string, echo.HandlerFunc) {
+			return health.HealthRouteFunc(s.rg.ConnectionState)
+		},
+		func(s *Server) (string, string, echo.HandlerFunc) {
+			return health.LivenessRouteFunc()
+		},
+		func(s *Server) (string, string, echo.HandlerFunc) {
+			return health.ReadinessRouteFunc(s.rg.ConnectionState, ro)
+		},
 	}
-	routeFuncs = append(routeFuncs, withPrefix("/api", apiRouteFuncs()...)...)
+	routeFuncs = append(routeFuncs, withPrefix("/api", withScope(auth.ReadScope, apiRouteFuncs()...)...)...)
+	if hs != nil {
+		routeFuncs = append(routeFuncs, withPrefix("/api", withScope(auth.ReadScope, historyRouteFunc)...)...)
+	}
+	if bc != nil {
+		routeFuncs = append(routeFuncs, withPrefix("/api", withScope(auth.StreamScope, streamRouteFunc)...)...)
+		e.Server.RegisterOnShutdown(bc.shutdown)
+		e.TLSServer.RegisterOnShutdown(bc.shutdown)
+	}
+	routeFuncs = append(routeFuncs, withPrefix("/api/admin", withScope(auth.AdminScope, adminRouteFuncs()...)...)...)
 	for _, routeFunc := range routeFuncs {
 		method, path, handler := routeFunc(s)
 		e.Add(method, path, handler)
@@ -49,26 +79,31 @@ func newServer(rg *redgiant.Redgiant, logger zerolog.Logger) *Server {
 		}
 
 		var rge errors.RedgiantErrorer
-		switch err := err.(type) {
-		case errors.RedgiantErrorer:
-			rge = err
-		case *echo.HTTPError:
+		var he *echo.HTTPError
+		switch {
+		case errors.As(err, &rge):
+		case errors.As(err, &he):
+			fault := errors.UnknownFault
+			if he.Code < http.StatusInternalServerError {
+				fault = errors.ClientFault
+			}
 			rge = errors.Wrap(
 				err,
-				errors.WithHTTPCode(err.Code),
+				errors.WithHTTPCode(he.Code),
 				errors.WithHTTPDetail(errors.MessageHTTPDetail),
+				errors.WithFault(fault),
 			).(*errors.RedgiantError)
 		default:
 			logger.Warn().Err(err).Msg("generic error")
 			rge = errors.New(err.Error(), errors.WithHTTPDetail(errors.NoHTTPDetail))
 		}
 		logger.Error().EmbedObject(rge).Send()
-		rge.SendAsResponse(c)
+		rge.Send(c, ef)
 	}
 
 	e.Use(middleware.RequestLoggerWithConfig(middleware.RequestLoggerConfig{
 		Skipper: func(c echo.Context) bool {
-			return c.Request().URL.Path == "/health" && logger.GetLevel() > zerolog.DebugLevel
+			return strings.HasPrefix(c.Request().URL.Path, "/health") && logger.GetLevel() > zerolog.DebugLevel
 		},
 		LogRemoteIP: true,
 		LogURI:      true,
@@ -76,7 +111,7 @@ func newServer(rg *redgiant.Redgiant, logger zerolog.Logger) *Server {
 		LogValuesFunc: func(c echo.Context, v middleware.RequestLoggerValues) error {
 			logger.Info().
 				Str("origin", v.RemoteIP).
-				Str("path", v.URI).
+				Str("path", auth.RedactURI(v.URI)).
 				Int("status_code", v.Status).
 				Msg("request")
 
@@ -87,15 +122,47 @@ func newServer(rg *redgiant.Redgiant, logger zerolog.Logger) *Server {
 	return s
 }
 
-func (s *Server) Start(host string, port uint, timeout time.Duration) error {
-	log := s.log.With().Str("host", host).Int("port", int(port)).Logger()
+func (s *Server) Start(c config.ServerConfig, timeout time.Duration) error {
+	log := s.log.With().Str("host", c.Host).Int("port", int(c.Port)).Bool("tls", c.TLS.Enabled).Logger()
 	log.Info().Msg("starting")
 
+	tc, err := certs.ServerTLSConfig(c)
+	if err != nil {
+		return err
+	}
+	hc, err := health.NewHTTPClient(c)
+	if err != nil {
+		return err
+	}
+
+	address := net.JoinHostPort(c.Host, strconv.Itoa(int(c.Port)))
 	go func() {
-		s.Echo.Start(fmt.Sprintf("%s:%d", host, port))
+		var err error
+		if tc != nil {
+			s.Echo.TLSServer.Addr = address
+			s.Echo.TLSServer.TLSConfig = tc
+			err = s.Echo.StartServer(s.Echo.TLSServer)
+		} else {
+			err = s.Echo.Start(address)
+		}
+		if err == http.ErrServerClosed {
+			err = nil
+		}
+		s.done <- err
+		close(s.done)
 	}()
 
-	if err := health.WaitForHealthy(host, port, timeout); err != nil {
+	healthy := make(chan error, 1)
+	go func() { healthy <- health.WaitForHealthy(hc, health.BaseURL(c), timeout) }()
+	select {
+	case err := <-healthy:
+		if err != nil {
+			return err
+		}
+	case err := <-s.done:
+		if err == nil {
+			err = errors.New("server stopped unexpectedly")
+		}
 		return err
 	}
 
@@ -103,10 +170,10 @@ func (s *Server) Start(host string, port uint, timeout time.Duration) error {
 	return nil
 }
 
-func wrapBasicRouteFunc(basicRouteFunc func() (string, string, echo.HandlerFunc)) routeFunc {
-	return func(*Server) (string, string, echo.HandlerFunc) {
-		return basicRouteFunc()
-	}
+// Done returns a channel that receives the error the server stopped with, or
+// nil if it was shut down.
+func (s *Server) Done() <-chan error {
+	return s.done
 }
 
 func withPrefix(prefix string, rfs ...routeFunc) []routeFunc {
@@ -119,3 +186,14 @@ func withPrefix(prefix string, rfs ...routeFunc) []routeFunc {
 	}
 	return prfs
 }
+
+func withScope(scope auth.Scope, rfs ...routeFunc) []routeFunc {
+	srfs := make([]routeFunc, 0, len(rfs))
+	for _, rf := range rfs {
+		srfs = append(srfs, func(s *Server) (string, string, echo.HandlerFunc) {
+			method, route, handlerFunc := rf(s)
+			return method, route, s.auth.Require(scope, handlerFunc)
+		})
+	}
+	return srfs
+}
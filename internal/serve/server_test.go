@@ -0,0 +1,314 @@
+package serve
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	rghttp "github.com/pmeier/redgiant/http"
+	"github.com/pmeier/redgiant/internal/auth"
+	"github.com/pmeier/redgiant/internal/config"
+	"github.com/pmeier/redgiant/internal/errors"
+	"github.com/pmeier/redgiant/internal/health"
+	"github.com/pmeier/redgiant/redgianttest"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testServer struct {
+	inv *redgianttest.Inverter
+	rg  *redgiant.Redgiant
+	bc  *broadcaster
+	srv *httptest.Server
+	c   *rghttp.Redgiant
+}
+
+func newTestServer(t *testing.T, ef errors.Format, opts ...redgiant.OptFunc) testServer {
+	rg, inv := redgianttest.NewRedgiant(t)
+
+	bc := newBroadcaster()
+	srv := httptest.NewServer(newServer(rg, nil, bc, auth.NewAuthorizer(), health.ReadinessOptions{}, nil, ef, zerolog.Nop()))
+	t.Cleanup(srv.Close)
+	t.Cleanup(bc.shutdown)
+	c, err := rghttp.NewRedgiantFromURL(srv.URL, append([]redgiant.OptFunc{redgiant.WithLogger(zerolog.Nop())}, opts...)...)
+	require.NoError(t, err)
+
+	return testServer{inv: inv, rg: rg, bc: bc, srv: srv, c: c}
+}
+
+func TestHTTPClient(t *testing.T) {
+	ts := newTestServer(t, errors.ProblemFormat)
+	redgianttest.TestClient(t, ts.c, ts.inv)
+}
+
+func TestHTTPClientErrors(t *testing.T) {
+	ts := newTestServer(t, errors.ProblemFormat)
+
+	_, err := ts.c.RealData(42, redgiant.NoLanguage)
+	require.Error(t, err)
+	assert.True(t, rghttp.IsUnknownDevice(err))
+	assert.False(t, rghttp.IsInverterDisconnected(err))
+	var e *rghttp.Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, map[string]any{"deviceID": float64(42)}, e.Context)
+
+	ts.inv.QueueResults("state", 42)
+	_, err = ts.c.State()
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, http.StatusBadGateway, e.StatusCode)
+	code, ok := rghttp.InverterResultCode(err)
+	assert.True(t, ok)
+	assert.Equal(t, 42, code)
+
+	ts.rg.Release(time.Minute)
+	_, err = ts.c.RealData(1, redgiant.NoLanguage)
+	assert.True(t, rghttp.IsInverterDisconnected(err))
+	assert.False(t, rghttp.IsUnknownDevice(err))
+	_, ok = rghttp.InverterResultCode(err)
+	assert.False(t, ok)
+}
+
+func TestHTTPClientEnergyFlow(t *testing.T) {
+	ts := newTestServer(t, errors.ProblemFormat)
+
+	expected, err := ts.rg.EnergyFlow(1)
+	require.NoError(t, err)
+	actual, err := ts.c.EnergyFlow(0)
+	require.NoError(t, err)
+	assert.Equal(t, 1, actual.DeviceID)
+	assert.Equal(t, expected.Metrics, actual.Metrics)
+	assert.Equal(t, expected.Nodes, actual.Nodes)
+	assert.Equal(t, expected.Edges, actual.Edges)
+
+	_, err = ts.c.EnergyFlow(2)
+	var e *rghttp.Error
+	require.ErrorAs(t, err, &e)
+	assert.Equal(t, http.StatusUnprocessableEntity, e.StatusCode)
+	assert.Equal(t, "unsupported-device", e.Code)
+}
+
+func TestErrorFormat(t *testing.T) {
+	tests := []struct {
+		format              errors.Format
+		expectedContentType string
+		expectedBody        string
+	}{
+		{
+			format:              errors.ProblemFormat,
+			expectedContentType: "application/problem+json",
+			expectedBody:        `{"type": "urn:redgiant:problem:unknown-device", "title": "Unknown device", "status": 422, "code": "unknown-device", "detail": "unknown device", "deviceID": 42}`,
+		},
+		{
+			format:              errors.LegacyFormat,
+			expectedContentType: "application/json",
+			expectedBody:        `{"error": {"message": "unknown device", "deviceID": 42}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.format.String(), func(t *testing.T) {
+			ts := newTestServer(t, test.format)
+
+			r, err := ts.srv.Client().Get(ts.srv.URL + "/api/data/42/real")
+			require.NoError(t, err)
+			defer r.Body.Close()
+			b, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, r.StatusCode)
+			assert.Equal(t, test.expectedContentType, r.Header.Get("Content-Type"))
+			assert.JSONEq(t, test.expectedBody, string(b))
+
+			// the client understands both formats
+			_, err = ts.c.RealData(42, redgiant.NoLanguage)
+			assert.True(t, rghttp.IsUnknownDevice(err))
+		})
+	}
+}
+
+func receive[T any](t *testing.T, ch <-chan T) T {
+	t.Helper()
+	select {
+	case v := <-ch:
+		return v
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timed out")
+	}
+	panic("unreachable")
+}
+
+func TestHTTPClientWatch(t *testing.T) {
+	ts := newTestServer(t, errors.ProblemFormat, redgiant.WithRetries(0, 10*time.Millisecond, 50*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	snapshot := func(n int) redgiant.Snapshot {
+		return redgiant.Snapshot{
+			Time:  time.Unix(int64(n), 0).UTC(),
+			About: ts.inv.About,
+			Devices: []redgiant.DeviceSnapshot{
+				{Device: ts.inv.Devices[0], Real: ts.inv.Real[1]["real_battery"]},
+				{Device: ts.inv.Devices[1], Real: ts.inv.Real[2]["real"]},
+			},
+		}
+	}
+
+	// without clients, the poller does not need to sample
+	assert.True(t, ts.bc.Idle())
+	ts.bc.Write(ctx, snapshot(1))
+	ts.bc.Availability(ctx, true)
+
+	w, err := ts.c.Watch(ctx, rghttp.WatchFilter{DeviceIDs: []int{1}, I18NCodes: []string{"I18N_COMMON_BATTERY_SOC"}})
+	require.NoError(t, err)
+	assert.False(t, ts.bc.Idle())
+
+	// the latest snapshot is replayed to new clients
+	s := receive(t, w.C)
+	assert.Equal(t, time.Unix(1, 0).UTC(), s.Time)
+	require.Len(t, s.Devices, 1)
+	assert.Equal(t, 1, s.Devices[0].Device.ID)
+	assert.Equal(t, ts.inv.Real[1]["real_battery"][:1], s.Devices[0].Real)
+	assert.Eventually(t, func() bool { return w.State().Connected && w.State().InverterAvailable }, time.Second, 10*time.Millisecond)
+
+	ts.bc.Write(ctx, snapshot(2))
+	assert.Equal(t, time.Unix(2, 0).UTC(), receive(t, w.C).Time)
+
+	// events published while the stream is lost are replayed on resume
+	ts.srv.CloseClientConnections()
+	ts.bc.Write(ctx, snapshot(3))
+	assert.Equal(t, time.Unix(3, 0).UTC(), receive(t, w.C).Time)
+	ts.bc.Write(ctx, snapshot(4))
+	assert.Equal(t, time.Unix(4, 0).UTC(), receive(t, w.C).Time)
+	assert.True(t, w.State().Connected)
+
+	cancel()
+	_, ok := <-w.C
+	assert.False(t, ok)
+}
+
+func TestReleaseReclaim(t *testing.T) {
+	ts := newTestServer(t, errors.ProblemFormat)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go ts.rg.KeepConnected(ctx, 10*time.Millisecond, 50*time.Millisecond)
+
+	do := func(method string, path string) int {
+		t.Helper()
+		r, err := http.NewRequest(method, ts.srv.URL+path, nil)
+		require.NoError(t, err)
+		resp, err := ts.srv.Client().Do(r)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	require.Equal(t, http.StatusOK, do(http.MethodPost, "/api/admin/release?minutes=60"))
+	assert.Equal(t, http.StatusServiceUnavailable, do(http.MethodGet, "/api/state"))
+	assert.False(t, ts.rg.ConnectionState().Connected)
+	// let KeepConnected start waiting for the end of the release
+	time.Sleep(50 * time.Millisecond)
+
+	// reclaiming reconnects right away rather than after the release ran out
+	require.Equal(t, http.StatusNoContent, do(http.MethodDelete, "/api/admin/release"))
+	assert.Eventually(t, func() bool { return ts.rg.ConnectionState().Connected }, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/api/state"))
+}
+
+func TestInverterUnavailable(t *testing.T) {
+	inv := redgianttest.NewInverter(t)
+	inv.SetOffline(true)
+	rg := redgiant.NewRedgiant(inv.NewSungrow(), redgiant.WithLogger(zerolog.Nop()))
+	t.Cleanup(rg.Close)
+	require.Error(t, rg.Connect())
+
+	srv := httptest.NewServer(newServer(rg, nil, nil, auth.NewAuthorizer(), health.ReadinessOptions{}, nil, errors.ProblemFormat, zerolog.Nop()))
+	t.Cleanup(srv.Close)
+
+	r, err := srv.Client().Get(srv.URL + "/api/state")
+	require.NoError(t, err)
+	defer r.Body.Close()
+	var problem map[string]any
+	require.NoError(t, json.NewDecoder(r.Body).Decode(&problem))
+
+	assert.Equal(t, http.StatusServiceUnavailable, r.StatusCode)
+	assert.Equal(t, "application/problem+json", r.Header.Get("Content-Type"))
+	assert.Equal(t, "5", r.Header.Get("Retry-After"))
+	assert.Equal(t, "urn:redgiant:problem:inverter-disconnected", problem["type"])
+	assert.Equal(t, "inverter-disconnected", problem["code"])
+	assert.Equal(t, float64(http.StatusServiceUnavailable), problem["status"])
+	assert.Equal(t, float64(5), problem["retryAfter"])
+	assert.NotEmpty(t, problem["reason"])
+
+	// the server recovers once the inverter is reachable again
+	inv.SetOffline(false)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go rg.KeepConnected(ctx, 10*time.Millisecond, 50*time.Millisecond)
+
+	c, err := rghttp.NewRedgiantFromURL(srv.URL, redgiant.WithLogger(zerolog.Nop()))
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		_, err := c.State()
+		return err == nil
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestMaxReadAge(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxReadAge time.Duration
+		expected   time.Duration
+	}{
+		{name: "default", maxReadAge: 0, expected: 30 * time.Second},
+		{name: "configured", maxReadAge: time.Minute, expected: time.Minute},
+		{name: "disabled", maxReadAge: -1, expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var c config.Config
+			c.Poll.Interval = 10 * time.Second
+			c.Health.MaxReadAge = test.maxReadAge
+			assert.Equal(t, test.expected, maxReadAge(c))
+		})
+	}
+}
+
+func TestSearchLanguages(t *testing.T) {
+	rg, _ := redgianttest.NewRedgiant(t)
+	srv := httptest.NewServer(newServer(rg, nil, nil, auth.NewAuthorizer(), health.ReadinessOptions{}, []redgiant.Language{redgiant.GermanLanguage}, errors.ProblemFormat, zerolog.Nop()))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []redgiant.Language
+	}{
+		{name: "default", query: "", expected: []redgiant.Language{redgiant.GermanLanguage}},
+		{name: "requested", query: "&lang=en", expected: []redgiant.Language{redgiant.EnglishLanguage}},
+		{name: "duplicates", query: "&lang=de&lang=en&lang=de-AT", expected: []redgiant.Language{redgiant.GermanLanguage, redgiant.EnglishLanguage}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := srv.Client().Get(srv.URL + "/api/search?q=I18N_COMMON_BATTERY_SOC" + test.query)
+			require.NoError(t, err)
+			defer r.Body.Close()
+			require.Equal(t, http.StatusOK, r.StatusCode)
+
+			var results []searchResult
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&results))
+			require.NotEmpty(t, results)
+			assert.Equal(t, "I18N_COMMON_BATTERY_SOC", results[0].I18NCode)
+			assert.ElementsMatch(t, test.expected, slices.Collect(maps.Keys(results[0].Names)))
+		})
+	}
+}
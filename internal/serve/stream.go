@@ -0,0 +1,282 @@
+package serve
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/poll"
+)
+
+const (
+	// streamBufferSize is the number of events kept for clients resuming a
+	// stream.
+	streamBufferSize = 64
+	// streamKeepAlive is the interval of comments sent to keep idle streams
+	// from being closed by proxies and to let clients detect dead streams.
+	streamKeepAlive = 15 * time.Second
+	// streamRetry is the reconnection delay suggested to clients.
+	streamRetry = 5 * time.Second
+)
+
+const (
+	sampleEvent       = "sample"
+	availabilityEvent = "availability"
+)
+
+type streamEvent struct {
+	seq  uint64
+	name string
+	// snapshot is set for sample events and available for availability events.
+	snapshot  *redgiant.Snapshot
+	available bool
+}
+
+// broadcaster is a sink that fans the samples of the poller out to the
+// clients of the stream endpoint. It is idle without clients, so the inverter
+// is not polled for nothing.
+type broadcaster struct {
+	// bootID distinguishes event IDs of different server runs, since the
+	// sequence numbers start over.
+	bootID    string
+	mu        sync.Mutex
+	seq       uint64
+	events    []streamEvent
+	available *streamEvent
+	subs      map[chan streamEvent]struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+var _ poll.AvailabilitySink = &broadcaster{}
+var _ poll.IdleSink = &broadcaster{}
+
+func newBroadcaster() *broadcaster {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return &broadcaster{bootID: hex.EncodeToString(b), subs: map[chan streamEvent]struct{}{}, done: make(chan struct{})}
+}
+
+func (b *broadcaster) eventID(seq uint64) string {
+	return fmt.Sprintf("%s-%d", b.bootID, seq)
+}
+
+// parseEventID returns the sequence number of an event ID of this server run.
+func (b *broadcaster) parseEventID(id string) (uint64, bool) {
+	bootID, seq, ok := strings.Cut(id, "-")
+	if !ok || bootID != b.bootID {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+func (b *broadcaster) publish(e streamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.seq++
+	e.seq = b.seq
+	if e.name == availabilityEvent {
+		b.available = &e
+	}
+	b.events = append(b.events, e)
+	if len(b.events) > streamBufferSize {
+		b.events = slices.Delete(b.events, 0, len(b.events)-streamBufferSize)
+	}
+
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			// The client cannot keep up. Closing the stream makes it resume
+			// from the last event it received.
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *broadcaster) Write(_ context.Context, s redgiant.Snapshot) error {
+	b.publish(streamEvent{name: sampleEvent, snapshot: &s})
+	return nil
+}
+
+// Availability only publishes changes.
+func (b *broadcaster) Availability(_ context.Context, available bool) error {
+	b.mu.Lock()
+	changed := b.available == nil || b.available.available != available
+	b.mu.Unlock()
+
+	if changed {
+		b.publish(streamEvent{name: availabilityEvent, available: available})
+	}
+	return nil
+}
+
+func (b *broadcaster) Idle() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs) == 0
+}
+
+// subscribe returns the events to replay and the channel of new events. With
+// the ID of the last event the client received, the events it missed are
+// replayed. Otherwise, the current availability and the latest sample are.
+func (b *broadcaster) subscribe(lastEventID string) ([]streamEvent, chan streamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []streamEvent
+	if seq, ok := b.parseEventID(lastEventID); ok && len(b.events) > 0 && seq+1 >= b.events[0].seq {
+		for _, e := range b.events {
+			if e.seq > seq {
+				replay = append(replay, e)
+			}
+		}
+	} else {
+		if b.available != nil {
+			replay = append(replay, *b.available)
+		}
+		for _, e := range slices.Backward(b.events) {
+			if e.name == sampleEvent {
+				replay = append(replay, e)
+				break
+			}
+		}
+	}
+
+	ch := make(chan streamEvent, streamBufferSize)
+	b.subs[ch] = struct{}{}
+	return replay, ch
+}
+
+func (b *broadcaster) unsubscribe(ch chan streamEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// shutdown ends all streams, which would otherwise keep the server from
+// shutting down.
+func (b *broadcaster) shutdown() {
+	b.closeOnce.Do(func() { close(b.done) })
+}
+
+func (b *broadcaster) Close() error {
+	b.shutdown()
+	return nil
+}
+
+type streamFilter struct {
+	DeviceIDs []int    `query:"device"`
+	I18NCodes []string `query:"metric"`
+}
+
+func (f streamFilter) apply(s redgiant.Snapshot) redgiant.Snapshot {
+	if len(f.DeviceIDs) == 0 && len(f.I18NCodes) == 0 {
+		return s
+	}
+
+	devices := []redgiant.DeviceSnapshot{}
+	for _, ds := range s.Devices {
+		if len(f.DeviceIDs) > 0 && !slices.Contains(f.DeviceIDs, ds.Device.ID) {
+			continue
+		}
+		if len(f.I18NCodes) > 0 {
+			ds.Real = slices.DeleteFunc(slices.Clone(ds.Real), func(m redgiant.RealMeasurement) bool {
+				return !slices.Contains(f.I18NCodes, m.I18NCode)
+			})
+			ds.Direct = slices.DeleteFunc(slices.Clone(ds.Direct), func(m redgiant.DirectMeasurement) bool {
+				return !slices.Contains(f.I18NCodes, m.I18NCode)
+			})
+		}
+		devices = append(devices, ds)
+	}
+	s.Devices = devices
+	return s
+}
+
+func streamRouteFunc(s *Server) (string, string, echo.HandlerFunc) {
+	return http.MethodGet, "/stream", func(c echo.Context) error {
+		var f streamFilter
+		if err := c.Bind(&f); err != nil {
+			return err
+		}
+		lastEventID := c.Request().Header.Get("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.QueryParam("lastEventId")
+		}
+
+		replay, ch := s.stream.subscribe(lastEventID)
+		defer s.stream.unsubscribe(ch)
+
+		w := c.Response()
+		w.Header().Set(echo.HeaderContentType, "text/event-stream")
+		w.Header().Set(echo.HeaderCacheControl, "no-cache")
+		// keep reverse proxies like nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+
+		for _, e := range replay {
+			if err := s.writeStreamEvent(w, e, f); err != nil {
+				return nil
+			}
+		}
+		w.Flush()
+
+		keepAlive := time.NewTicker(streamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case <-c.Request().Context().Done():
+				return nil
+			case <-s.stream.done:
+				return nil
+			case e, ok := <-ch:
+				if !ok {
+					return nil
+				}
+				if err := s.writeStreamEvent(w, e, f); err != nil {
+					return nil
+				}
+			case <-keepAlive.C:
+				if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+					return nil
+				}
+			}
+			w.Flush()
+		}
+	}
+}
+
+func (s *Server) writeStreamEvent(w *echo.Response, e streamEvent, f streamFilter) error {
+	var v any
+	switch e.name {
+	case sampleEvent:
+		v = f.apply(*e.snapshot)
+	case availabilityEvent:
+		v = map[string]bool{"available": e.available}
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", s.stream.eventID(e.seq), e.name, data)
+	return err
+}
@@ -1,16 +1,34 @@
 package redgiant
 
 import (
+	"crypto/tls"
 	"net/http"
+	"time"
 
 	"github.com/rs/zerolog"
 )
 
 type Options struct {
-	Logger         zerolog.Logger
-	Localizer      Localizer
-	HTTPClient     *http.Client
-	ReconnectTries uint
+	Logger             zerolog.Logger
+	Localizer          Localizer
+	HTTPClient         *http.Client
+	TLSConfig          *tls.Config
+	InsecureSkipVerify bool
+	ReconnectTries     uint
+	ResultPolicies     map[int]ResultPolicy
+	ResultRetries      uint
+	ResultRetryDelay   time.Duration
+	ConnectionMode     ConnectionMode
+	IdleTimeout        time.Duration
+	CacheDir           string
+	FirmwareVersion    func() (string, error)
+	Overrides          map[Language]map[string]string
+	Retries            uint
+	MinRetryBackoff    time.Duration
+	MaxRetryBackoff    time.Duration
+	UserAgent          string
+	RequestAuth        func(*http.Request)
+	UnixSocket         string
 }
 
 type OptFunc = func(*Options)
@@ -41,8 +59,134 @@ func WithHTTPClient(c *http.Client) OptFunc {
 	}
 }
 
+// WithTLSConfig sets the TLS configuration of the HTTP client, e.g. to trust
+// custom CAs. It is applied after WithHTTPClient regardless of the order.
+func WithTLSConfig(c *tls.Config) OptFunc {
+	return func(opts *Options) {
+		opts.TLSConfig = c
+	}
+}
+
+// WithInsecureSkipVerify disables the verification of server certificates.
+// Prefer WithTLSConfig to trust the CA of the server instead.
+func WithInsecureSkipVerify(skip bool) OptFunc {
+	return func(opts *Options) {
+		opts.InsecureSkipVerify = skip
+	}
+}
+
+// ApplyTLSConfig returns the HTTP client with the TLS configuration applied.
+// The client is copied rather than modified.
+func (o *Options) ApplyTLSConfig() *http.Client {
+	if o.TLSConfig == nil && !o.InsecureSkipVerify {
+		return o.HTTPClient
+	}
+
+	c := *o.HTTPClient
+	var t *http.Transport
+	if ht, ok := c.Transport.(*http.Transport); ok {
+		t = ht.Clone()
+	} else {
+		t = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	if o.TLSConfig != nil {
+		t.TLSClientConfig = o.TLSConfig.Clone()
+	} else if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	if o.InsecureSkipVerify {
+		t.TLSClientConfig.InsecureSkipVerify = true
+	}
+	c.Transport = t
+	return &c
+}
+
 func WithReconnect(retries uint) OptFunc {
 	return func(opts *Options) {
 		opts.ReconnectTries = retries
 	}
 }
+
+// WithResultPolicies overrides how result codes of the inverter are handled,
+// e.g. to retry a code the firmware answers with while it is busy. Codes that
+// are not overridden are handled as reported by ResultCodePolicy.
+func WithResultPolicies(policies map[int]ResultPolicy) OptFunc {
+	return func(opts *Options) {
+		opts.ResultPolicies = policies
+	}
+}
+
+// WithResultRetries sets how often requests answered with a result code of
+// RetryResultPolicy are sent again and the delay in between.
+func WithResultRetries(retries uint, delay time.Duration) OptFunc {
+	return func(opts *Options) {
+		opts.ResultRetries = retries
+		opts.ResultRetryDelay = delay
+	}
+}
+
+// WithConnectionMode selects how the connection to the inverter is managed.
+// The idle timeout only applies to OnDemandConnectionMode.
+func WithConnectionMode(mode ConnectionMode, idleTimeout time.Duration) OptFunc {
+	return func(opts *Options) {
+		opts.ConnectionMode = mode
+		opts.IdleTimeout = idleTimeout
+	}
+}
+
+// WithCacheDir persists downloaded translations in the given directory. An
+// empty directory disables the cache.
+func WithCacheDir(dir string) OptFunc {
+	return func(opts *Options) {
+		opts.CacheDir = dir
+	}
+}
+
+// WithFirmwareVersion sets the function the translation cache is keyed by,
+// since the translations shipped by the inverter change with its firmware.
+func WithFirmwareVersion(fn func() (string, error)) OptFunc {
+	return func(opts *Options) {
+		opts.FirmwareVersion = fn
+	}
+}
+
+// WithTranslationOverrides layers the translations on top of the localizer,
+// see OverrideLocalizer.
+func WithTranslationOverrides(overrides map[Language]map[string]string) OptFunc {
+	return func(opts *Options) {
+		opts.Overrides = overrides
+	}
+}
+
+// WithRetries retries failed requests to a server. The backoff doubles from
+// minBackoff up to maxBackoff, unless the server asks to retry after a given
+// time. Responses asking for longer than maxBackoff are not retried.
+func WithRetries(retries uint, minBackoff time.Duration, maxBackoff time.Duration) OptFunc {
+	return func(opts *Options) {
+		opts.Retries = retries
+		opts.MinRetryBackoff = minBackoff
+		opts.MaxRetryBackoff = maxBackoff
+	}
+}
+
+func WithUserAgent(userAgent string) OptFunc {
+	return func(opts *Options) {
+		opts.UserAgent = userAgent
+	}
+}
+
+// WithRequestAuth sets the function that adds credentials to requests to a
+// server.
+func WithRequestAuth(fn func(*http.Request)) OptFunc {
+	return func(opts *Options) {
+		opts.RequestAuth = fn
+	}
+}
+
+// WithUnixSocket connects to a server through the unix socket at path rather
+// than the host of its URL.
+func WithUnixSocket(path string) OptFunc {
+	return func(opts *Options) {
+		opts.UnixSocket = path
+	}
+}
@@ -0,0 +1,127 @@
+package redgiant
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/pmeier/redgiant/internal/errors"
+	"github.com/pmeier/redgiant/internal/properties"
+	"gopkg.in/yaml.v3"
+)
+
+// OverrideLocalizer looks up user-supplied translations before falling back
+// to another Localizer, typically a SungrowLocalizer. It can also add
+// languages the fallback does not support.
+type OverrideLocalizer struct {
+	overrides map[Language]map[string]string
+	fallback  Localizer
+}
+
+func NewOverrideLocalizer(fallback Localizer, overrides map[Language]map[string]string) *OverrideLocalizer {
+	return &OverrideLocalizer{overrides: overrides, fallback: fallback}
+}
+
+func (l *OverrideLocalizer) Localize(i18nCode string, lang Language) (string, error) {
+	code, args := splitArgs(i18nCode)
+	if v, ok := l.overrides[lang][code]; ok {
+		return formatArgs(v, args), nil
+	}
+	return l.fallback.Localize(i18nCode, lang)
+}
+
+// Catalog returns the translations of the fallback, if it supports listing
+// them, with the overrides applied.
+func (l *OverrideLocalizer) Catalog(lang Language) (map[string]string, error) {
+	catalog := map[string]string{}
+	if c, ok := l.fallback.(Cataloger); ok {
+		fc, err := c.Catalog(lang)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(catalog, fc)
+	}
+	maps.Copy(catalog, l.overrides[lang])
+	return catalog, nil
+}
+
+// Preload preloads the languages of the fallback, if it supports it.
+func (l *OverrideLocalizer) Preload(langs ...Language) error {
+	if p, ok := l.fallback.(Preloader); ok {
+		return p.Preload(langs...)
+	}
+	return nil
+}
+
+// Languages returns the languages of the fallback as well as the ones only
+// available through overrides.
+func (l *OverrideLocalizer) Languages() []Language {
+	var langs []Language
+	if ll, ok := l.fallback.(LanguageLister); ok {
+		langs = ll.Languages()
+	} else {
+		langs = slices.Clone(builtinLanguages)
+	}
+	for _, lang := range slices.Sorted(maps.Keys(l.overrides)) {
+		if !slices.Contains(langs, lang) {
+			langs = append(langs, lang)
+		}
+	}
+	return langs
+}
+
+// LoadOverrides reads the override files in dir. They are named after the
+// language they apply to, e.g. de_DE.yaml or de-AT.properties, and map i18n
+// codes to names. YAML files hold a flat mapping. Multiple files for the same
+// language are merged in lexical order.
+func LoadOverrides(dir string) (map[Language]map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+
+	overrides := map[Language]map[string]string{}
+	for _, e := range entries {
+		ext := filepath.Ext(e.Name())
+		if e.IsDir() || !slices.Contains([]string{".properties", ".yaml", ".yml"}, ext) {
+			continue
+		}
+
+		file := filepath.Join(dir, e.Name())
+		lang, err := ParseLanguage(strings.TrimSuffix(e.Name(), ext))
+		if err != nil {
+			return nil, errors.New("unknown language of override file", errors.WithContext(errors.Context{"file": file}))
+		}
+
+		codes, err := readOverrideFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if overrides[lang] == nil {
+			overrides[lang] = map[string]string{}
+		}
+		maps.Copy(overrides[lang], codes)
+	}
+	return overrides, nil
+}
+
+func readOverrideFile(file string) (map[string]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	defer f.Close()
+
+	codes := map[string]string{}
+	if filepath.Ext(file) == ".properties" {
+		codes, err = properties.Parse(f)
+	} else {
+		err = yaml.NewDecoder(f).Decode(&codes)
+	}
+	if err != nil {
+		return nil, errors.New(err.Error(), errors.WithContext(errors.Context{"file": file}))
+	}
+	return codes, nil
+}
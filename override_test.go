@@ -0,0 +1,68 @@
+package redgiant
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type staticLocalizer map[Language]map[string]string
+
+func (l staticLocalizer) Localize(i18nCode string, lang Language) (string, error) {
+	if v, ok := l[lang][i18nCode]; ok {
+		return v, nil
+	}
+	return i18nCode, nil
+}
+
+func TestOverrideLocalizer(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"de.yaml":          "I18N_COMMON_BATTERY_SOC: Ladestand\nI18N_ARGS: \"{1} von {0}\"\n",
+		"de_DE.properties": "I18N_COMMON_TOTAL_YIELD = Gesamtertrag\n",
+		"fr-FR.yml":        "I18N_COMMON_BATTERY_SOC: Niveau de batterie\n",
+		"README.md":        "ignored",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	overrides, err := LoadOverrides(dir)
+	require.NoError(t, err)
+
+	fallback := staticLocalizer{GermanLanguage: {"I18N_COMMON_BATTERY_SOC": "Batteriestand", "I18N_COMMON_GRID_FREQUENCY": "Netzfrequenz"}}
+	l := NewOverrideLocalizer(fallback, overrides)
+
+	tests := []struct {
+		i18nCode string
+		lang     Language
+		expected string
+	}{
+		{i18nCode: "I18N_COMMON_BATTERY_SOC", lang: GermanLanguage, expected: "Ladestand"},
+		{i18nCode: "I18N_COMMON_TOTAL_YIELD", lang: GermanLanguage, expected: "Gesamtertrag"},
+		{i18nCode: "I18N_COMMON_GRID_FREQUENCY", lang: GermanLanguage, expected: "Netzfrequenz"},
+		{i18nCode: "I18N_ARGS%@a%@b", lang: GermanLanguage, expected: "b von a"},
+		{i18nCode: "I18N_COMMON_BATTERY_SOC", lang: "fr_FR", expected: "Niveau de batterie"},
+		{i18nCode: "I18N_COMMON_BATTERY_SOC", lang: NoLanguage, expected: "I18N_COMMON_BATTERY_SOC"},
+	}
+	for _, test := range tests {
+		t.Run(test.lang.String()+"/"+test.i18nCode, func(t *testing.T) {
+			actual, err := l.Localize(test.i18nCode, test.lang)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+
+	assert.Equal(t, append(slices.Clone(builtinLanguages), "fr_FR"), l.Languages())
+}
+
+func TestLoadOverridesUnknownLanguage(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "english.yaml"), []byte("a: b\n"), 0o644))
+
+	_, err := LoadOverrides(dir)
+	assert.Error(t, err)
+}
@@ -2,12 +2,16 @@ package redgiant
 
 import (
 	"net/http"
+	"slices"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/pmeier/redgiant/internal/errors"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/text/language"
 )
 
 type deviceInfo struct {
@@ -16,31 +20,152 @@ type deviceInfo struct {
 }
 
 type Redgiant struct {
-	sg            *Sungrow
-	log           zerolog.Logger
-	localizer     Localizer
-	deviceInfoMap map[int]deviceInfo
+	sg             *Sungrow
+	log            zerolog.Logger
+	localizer      Localizer
+	deviceInfoMu   sync.Mutex
+	deviceInfoMap  map[int]deviceInfo
+	connectionMode ConnectionMode
+	idleTimeout    time.Duration
+	stateMu        sync.Mutex
+	state          ConnectionState
+	releasedUntil  time.Time
+	lastUse        atomic.Int64
+	about          atomic.Pointer[About]
+	// wake interrupts the wait of KeepConnected, e.g. when a release ends.
+	wake chan struct{}
 }
 
 func NewRedgiant(sg *Sungrow, opts ...OptFunc) *Redgiant {
 	o := ResolveOptions(append([]OptFunc{
 		WithLogger(log.Logger),
-		WithLocalizer(NewSungrowLocalizer(sg.Host)),
+		WithConnectionMode(PersistentConnectionMode, 0),
 	}, opts...)...)
-	return &Redgiant{sg: sg, log: o.Logger, localizer: o.Localizer}
+	rg := &Redgiant{
+		sg:             sg,
+		log:            o.Logger,
+		localizer:      o.Localizer,
+		connectionMode: o.ConnectionMode,
+		idleTimeout:    o.IdleTimeout,
+		wake:           make(chan struct{}, 1),
+	}
+	if rg.localizer == nil {
+		rg.localizer = NewSungrowLocalizer(
+			sg.Host,
+			WithLogger(o.Logger),
+			WithHTTPClient(sg.c),
+			WithCacheDir(o.CacheDir),
+			WithFirmwareVersion(rg.firmwareVersion),
+		)
+	}
+	if len(o.Overrides) > 0 {
+		rg.localizer = NewOverrideLocalizer(rg.localizer, o.Overrides)
+	}
+	return rg
+}
+
+// cachedAbout returns the information about the inverter. It is cached until
+// the next connection.
+func (rg *Redgiant) cachedAbout() (About, error) {
+	if a := rg.about.Load(); a != nil {
+		return *a, nil
+	}
+	return rg.About()
+}
+
+// firmwareVersion returns the version of the inverter's communication module,
+// which serves the translations.
+func (rg *Redgiant) firmwareVersion() (string, error) {
+	a, err := rg.cachedAbout()
+	if err != nil {
+		return "", err
+	}
+	return a.Version, nil
+}
+
+// inverterSerial returns the serial number measurements are attributed to. It
+// is only used for metadata, so failing to read it is not an error.
+func (rg *Redgiant) inverterSerial() string {
+	a, err := rg.cachedAbout()
+	if err != nil {
+		rg.log.Debug().Err(err).Msg("unable to read inverter serial")
+		return ""
+	}
+	return a.SerialNumber
+}
+
+// Languages returns the languages measurements can be localized in.
+func (rg *Redgiant) Languages() []Language {
+	if ll, ok := rg.localizer.(LanguageLister); ok {
+		return ll.Languages()
+	}
+	return slices.Clone(builtinLanguages)
+}
+
+// Preload loads the languages ahead of their first use, if the localizer
+// supports it.
+func (rg *Redgiant) Preload(langs ...Language) error {
+	if p, ok := rg.localizer.(Preloader); ok {
+		return p.Preload(langs...)
+	}
+	return nil
 }
 
-func (rg *Redgiant) Connect() error {
-	return rg.sg.Connect()
+// MatchLanguage returns the language that fits the preferred ones best.
+func (rg *Redgiant) MatchLanguage(preferred ...language.Tag) Language {
+	return MatchLanguage(rg.Languages(), preferred...)
+}
+
+func (rg *Redgiant) cataloger() (Cataloger, error) {
+	c, ok := rg.localizer.(Cataloger)
+	if !ok {
+		return nil, errors.New(
+			"localizer does not support reverse lookups",
+			errors.WithCode(errors.NotImplementedCode),
+			errors.WithHTTPCode(http.StatusNotImplemented),
+			errors.WithHTTPDetail(errors.MessageHTTPDetail),
+		)
+	}
+	return c, nil
+}
+
+// Lookup returns the i18n code of a localized name, see Lookup.
+func (rg *Redgiant) Lookup(name string, lang Language) (string, error) {
+	c, err := rg.cataloger()
+	if err != nil {
+		return "", err
+	}
+	return Lookup(c, name, lang)
+}
+
+// Search fuzzily searches the names of i18n codes, see Search. Without
+// languages, all supported languages are searched.
+func (rg *Redgiant) Search(query string, langs ...Language) ([]SearchResult, error) {
+	c, err := rg.cataloger()
+	if err != nil {
+		return nil, err
+	}
+	if len(langs) == 0 {
+		langs = rg.Languages()
+	}
+	return Search(c, query, langs...)
 }
 
 func (rg *Redgiant) Close() {
 	rg.sg.Close()
 }
 
+func (rg *Redgiant) IsConnected() bool {
+	return rg.sg.IsConnected()
+}
+
 func (rg *Redgiant) About() (About, error) {
 	rg.log.Trace().Msg("Redgiant.About()")
 
+	if err := rg.acquire(); err != nil {
+		return About{}, err
+	}
+
 	type Data struct {
 		Measurements []sungrowRealMeasurement `json:"list"`
 	}
@@ -54,17 +179,23 @@ func (rg *Redgiant) About() (About, error) {
 		ms[m.DataName] = m.DataValue
 	}
 
-	return About{
+	a := About{
 		SerialNumber:    ms["I18N_COMMON_DEVICE_SN"],
 		Version:         ms["I18N_COMMON_VERSION"],
 		SoftwareVersion: ms["I18N_COMMON_APPLI_SOFT_VERSION"],
 		BuildVersion:    ms["I18N_COMMON_BUILD_SOFT_VERSION"],
-	}, nil
+	}
+	rg.about.Store(&a)
+	return a, nil
 }
 
 func (rg *Redgiant) State() (State, error) {
 	rg.log.Trace().Msg("Redgiant.State()")
 
+	if err := rg.acquire(); err != nil {
+		return State{}, err
+	}
+
 	var s sungrowState
 	if err := rg.sg.Send("state", nil, &s); err != nil {
 		return State{}, err
@@ -75,6 +206,10 @@ func (rg *Redgiant) State() (State, error) {
 func (rg *Redgiant) Devices() ([]Device, error) {
 	rg.log.Trace().Msg("Redgiant.Devices()")
 
+	if err := rg.acquire(); err != nil {
+		return nil, err
+	}
+
 	type Data struct {
 		Devices []sungrowDevice `json:"list"`
 	}
@@ -99,6 +234,9 @@ func (rg *Redgiant) Devices() ([]Device, error) {
 func (rg *Redgiant) getDeviceInfo(deviceID int) (deviceInfo, error) {
 	rg.log.Trace().Msg("Redgiant.getDeviceInfo()")
 
+	rg.deviceInfoMu.Lock()
+	defer rg.deviceInfoMu.Unlock()
+
 	if rg.deviceInfoMap == nil {
 		devices, err := rg.Devices()
 		if err != nil {
@@ -116,8 +254,10 @@ func (rg *Redgiant) getDeviceInfo(deviceID int) (deviceInfo, error) {
 	if !ok {
 		return deviceInfo{}, errors.New(
 			"unknown device",
+			errors.WithCode(errors.UnknownDeviceCode),
 			errors.WithContext(errors.Context{"deviceID": deviceID}),
 			errors.WithHTTPCode(http.StatusUnprocessableEntity),
+			errors.WithFault(errors.ClientFault),
 			errors.WithHTTPDetail(errors.ContextHTTPDetail),
 		)
 	}
@@ -133,6 +273,10 @@ var availableRealDataServices = map[int][]string{
 func (rg *Redgiant) RealData(deviceID int, lang Language, services ...string) ([]RealMeasurement, error) {
 	rg.log.Trace().Int("deviceID", deviceID).Stringer("lang", lang).Strs("services", services).Msg("Redgiant.RealData()")
 
+	if err := rg.acquire(); err != nil {
+		return nil, err
+	}
+
 	info, err := rg.getDeviceInfo(deviceID)
 	if err != nil {
 		return nil, err
@@ -145,8 +289,10 @@ func (rg *Redgiant) RealData(deviceID int, lang Language, services ...string) ([
 		if !ok {
 			return nil, errors.New(
 				"unknown device type",
+				errors.WithCode(errors.UnknownDeviceTypeCode),
 				errors.WithContext(errors.Context{"deviceType": info.Type}),
 				errors.WithHTTPCode(http.StatusUnprocessableEntity),
+				errors.WithFault(errors.ClientFault),
 				errors.WithHTTPDetail(errors.ContextHTTPDetail),
 			)
 		}
@@ -160,6 +306,7 @@ func (rg *Redgiant) RealData(deviceID int, lang Language, services ...string) ([
 	}
 	var d Data
 	ms := []RealMeasurement{}
+	serial := rg.inverterSerial()
 	for _, service := range services {
 		if err := rg.sg.Send(service, map[string]any{"dev_id": strconv.Itoa(info.ID), "time123456": time.Now().Unix()}, &d); err != nil {
 			if strict {
@@ -169,8 +316,10 @@ func (rg *Redgiant) RealData(deviceID int, lang Language, services ...string) ([
 			}
 
 		}
+		sample := Sample{DeviceID: info.ID, Service: service, SampledAt: time.Now(), InverterSerial: serial}
 		for _, sm := range d.Measurements {
 			m := sm.ToRedgiant()
+			m.Sample = sample
 			if name, err := rg.localizer.Localize(m.I18NCode, lang); err == nil {
 				m.Name = name
 			} else {
@@ -196,6 +345,10 @@ var availableDirectDataServices = map[int][]string{
 func (rg *Redgiant) DirectData(deviceID int, lang Language, services ...string) ([]DirectMeasurement, error) {
 	rg.log.Trace().Int("deviceID", deviceID).Stringer("lang", lang).Strs("services", services).Msg("Redgiant.DirectData()")
 
+	if err := rg.acquire(); err != nil {
+		return nil, err
+	}
+
 	info, err := rg.getDeviceInfo(deviceID)
 	if err != nil {
 		return nil, err
@@ -208,8 +361,10 @@ func (rg *Redgiant) DirectData(deviceID int, lang Language, services ...string)
 		if !ok {
 			return nil, errors.New(
 				"unknown device type",
+				errors.WithCode(errors.UnknownDeviceTypeCode),
 				errors.WithContext(errors.Context{"deviceType": info.Type}),
 				errors.WithHTTPCode(http.StatusUnprocessableEntity),
+				errors.WithFault(errors.ClientFault),
 				errors.WithHTTPDetail(errors.ContextHTTPDetail),
 			)
 		}
@@ -223,6 +378,7 @@ func (rg *Redgiant) DirectData(deviceID int, lang Language, services ...string)
 	}
 	var d Data
 	ms := []DirectMeasurement{}
+	serial := rg.inverterSerial()
 	for _, service := range services {
 		if err := rg.sg.Send(service, map[string]any{"dev_id": strconv.Itoa(info.ID), "time123456": time.Now().Unix()}, &d); err != nil {
 			if strict {
@@ -231,8 +387,10 @@ func (rg *Redgiant) DirectData(deviceID int, lang Language, services ...string)
 				continue
 			}
 		}
+		sample := Sample{DeviceID: info.ID, Service: service, SampledAt: time.Now(), InverterSerial: serial}
 		for _, sm := range d.Measurements {
 			m := sm.ToRedgiant()
+			m.Sample = sample
 			name, err := rg.localizer.Localize(m.I18NCode, lang)
 			if err == nil {
 				m.Name = name
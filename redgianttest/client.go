@@ -0,0 +1,183 @@
+package redgianttest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestClient checks that the client reads the data of the inverter correctly.
+// The client has to be connected to inv, directly or through a server.
+func TestClient(t *testing.T, c redgiant.Client, inv *Inverter) {
+	langs := []redgiant.Language{redgiant.NoLanguage, redgiant.EnglishLanguage, redgiant.GermanLanguage}
+	start := time.Now()
+
+	t.Run("About", func(t *testing.T) {
+		a, err := c.About()
+		require.NoError(t, err)
+		assert.Equal(t, inv.About, a)
+	})
+
+	t.Run("State", func(t *testing.T) {
+		s, err := c.State()
+		require.NoError(t, err)
+		assert.Equal(t, inv.State, s)
+	})
+
+	t.Run("Devices", func(t *testing.T) {
+		ds, err := c.Devices()
+		require.NoError(t, err)
+		assert.Equal(t, inv.Devices, ds)
+	})
+
+	t.Run("RealData", func(t *testing.T) {
+		for deviceID, services := range inv.Real {
+			for _, lang := range langs {
+				var expected []redgiant.RealMeasurement
+				for service, ms := range services {
+					expected = append(expected, inv.localizeReal(deviceID, service, ms, lang)...)
+				}
+
+				actual, err := c.RealData(deviceID, lang)
+				require.NoError(t, err, "device %d, language %q", deviceID, lang)
+				for i := range actual {
+					actual[i].SampledAt = checkSampledAt(t, start, actual[i].SampledAt)
+				}
+				assert.ElementsMatch(t, expected, actual, "device %d, language %q", deviceID, lang)
+
+				for service, ms := range services {
+					actual, err := c.RealData(deviceID, lang, service)
+					require.NoError(t, err, "device %d, language %q, service %s", deviceID, lang, service)
+					for i := range actual {
+						actual[i].SampledAt = checkSampledAt(t, start, actual[i].SampledAt)
+					}
+					assert.Equal(t, inv.localizeReal(deviceID, service, ms, lang), actual, "device %d, language %q, service %s", deviceID, lang, service)
+				}
+			}
+		}
+	})
+
+	t.Run("DirectData", func(t *testing.T) {
+		for deviceID, services := range inv.Direct {
+			for _, lang := range langs {
+				var expected []redgiant.DirectMeasurement
+				for service, ms := range services {
+					expected = append(expected, inv.localizeDirect(deviceID, service, ms, lang)...)
+				}
+
+				actual, err := c.DirectData(deviceID, lang)
+				require.NoError(t, err, "device %d, language %q", deviceID, lang)
+				for i := range actual {
+					actual[i].SampledAt = checkSampledAt(t, start, actual[i].SampledAt)
+				}
+				assert.ElementsMatch(t, expected, actual, "device %d, language %q", deviceID, lang)
+			}
+		}
+	})
+
+	t.Run("Snapshot", func(t *testing.T) {
+		for _, lang := range langs {
+			s, err := c.Snapshot(lang)
+			require.NoError(t, err, "language %q", lang)
+			assert.False(t, s.Time.Before(start.Truncate(time.Second)), "language %q", lang)
+			assert.Equal(t, inv.About, s.About, "language %q", lang)
+			assert.Equal(t, inv.State, s.State, "language %q", lang)
+			require.Len(t, s.Devices, len(inv.Devices), "language %q", lang)
+
+			for i, ds := range s.Devices {
+				d := inv.Devices[i]
+				assert.Equal(t, d, ds.Device, "language %q", lang)
+				assert.Empty(t, ds.Errors, "device %d, language %q", d.ID, lang)
+
+				expectedReal := []redgiant.RealMeasurement{}
+				for service, ms := range inv.Real[d.ID] {
+					expectedReal = append(expectedReal, inv.localizeReal(d.ID, service, ms, lang)...)
+				}
+				for i := range ds.Real {
+					ds.Real[i].SampledAt = checkSampledAt(t, start, ds.Real[i].SampledAt)
+				}
+				assert.ElementsMatch(t, expectedReal, ds.Real, "device %d, language %q", d.ID, lang)
+
+				expectedDirect := []redgiant.DirectMeasurement{}
+				for service, ms := range inv.Direct[d.ID] {
+					expectedDirect = append(expectedDirect, inv.localizeDirect(d.ID, service, ms, lang)...)
+				}
+				for i := range ds.Direct {
+					ds.Direct[i].SampledAt = checkSampledAt(t, start, ds.Direct[i].SampledAt)
+				}
+				assert.ElementsMatch(t, expectedDirect, ds.Direct, "device %d, language %q", d.ID, lang)
+			}
+		}
+	})
+
+	t.Run("UnknownDevice", func(t *testing.T) {
+		deviceID := 0
+		for _, d := range inv.Devices {
+			deviceID = max(deviceID, d.ID+1)
+		}
+
+		_, err := c.RealData(deviceID, redgiant.NoLanguage)
+		assert.Error(t, err)
+		_, err = c.DirectData(deviceID, redgiant.NoLanguage)
+		assert.Error(t, err)
+	})
+
+	t.Run("UnknownService", func(t *testing.T) {
+		for deviceID := range inv.Real {
+			_, err := c.RealData(deviceID, redgiant.NoLanguage, "unknown")
+			assert.Error(t, err, "device %d", deviceID)
+		}
+	})
+}
+
+// checkSampledAt checks that a measurement was sampled during the test and
+// returns the zero time, so the measurement can be compared to the expected
+// one.
+func checkSampledAt(t *testing.T, start time.Time, sampledAt time.Time) time.Time {
+	t.Helper()
+	assert.False(t, sampledAt.Before(start.Truncate(time.Second)), "sampled at %s before the test started", sampledAt)
+	assert.False(t, sampledAt.After(time.Now()), "sampled at %s in the future", sampledAt)
+	return time.Time{}
+}
+
+func (inv *Inverter) sample(deviceID int, service string) redgiant.Sample {
+	return redgiant.Sample{DeviceID: deviceID, Service: service, InverterSerial: inv.About.SerialNumber}
+}
+
+func (inv *Inverter) localize(s string, lang redgiant.Language) (string, bool) {
+	if lang == redgiant.NoLanguage {
+		return s, true
+	}
+	t, ok := inv.Translations[lang][s]
+	return t, ok
+}
+
+func (inv *Inverter) localizeReal(deviceID int, service string, ms []redgiant.RealMeasurement, lang redgiant.Language) []redgiant.RealMeasurement {
+	lms := make([]redgiant.RealMeasurement, 0, len(ms))
+	for _, m := range ms {
+		m.Sample = inv.sample(deviceID, service)
+		if name, ok := inv.localize(m.I18NCode, lang); ok {
+			m.Name = name
+		} else {
+			m.Name = m.I18NCode
+		}
+		if value, ok := inv.localize(m.Value, lang); ok {
+			m.Value = value
+		}
+		lms = append(lms, m)
+	}
+	return lms
+}
+
+func (inv *Inverter) localizeDirect(deviceID int, service string, ms []redgiant.DirectMeasurement, lang redgiant.Language) []redgiant.DirectMeasurement {
+	lms := make([]redgiant.DirectMeasurement, 0, len(ms))
+	for _, m := range ms {
+		m.Sample = inv.sample(deviceID, service)
+		m.Name, _ = inv.localize(m.I18NCode, lang)
+		lms = append(lms, m)
+	}
+	return lms
+}
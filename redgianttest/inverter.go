@@ -0,0 +1,375 @@
+// Package redgianttest implements utilities for testing code built on top of
+// redgiant.
+package redgianttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/internal/properties"
+	"github.com/rs/zerolog"
+)
+
+// Inverter emulates the web interface of a Sungrow inverter. The data it serves
+// may be changed until the first client connects.
+type Inverter struct {
+	About   redgiant.About
+	State   redgiant.State
+	Devices []redgiant.Device
+	// Real and Direct are the measurements of the devices by device ID and
+	// service. Their names are left empty.
+	Real   map[int]map[string][]redgiant.RealMeasurement
+	Direct map[int]map[string][]redgiant.DirectMeasurement
+	// Translations are the i18n files served by language.
+	Translations map[redgiant.Language]map[string]string
+
+	srv      *httptest.Server
+	offline  atomic.Bool
+	upgrader websocket.Upgrader
+	mu       sync.Mutex
+	tokens   map[string]bool
+	results  map[string][]int
+}
+
+// NewInverter starts an emulated inverter serving a hybrid inverter with a
+// battery and a smart meter. It is closed at the end of the test.
+func NewInverter(t testing.TB) *Inverter {
+	inv := &Inverter{
+		About: redgiant.About{
+			SerialNumber:    "A2340000000",
+			Version:         "WINET-SV200.001.00.P027",
+			SoftwareVersion: "SAPPHIRE-H_01011.95.12",
+			BuildVersion:    "M_WiNet-S_V01_V01_A",
+		},
+		State: redgiant.State{
+			TotalFaults:         0,
+			TotalAlarms:         1,
+			WifiConnection:      true,
+			Ethernet1Connection: true,
+			CloudConnection:     true,
+		},
+		Devices: []redgiant.Device{
+			{ID: 1, Code: 3599, Type: 35, Protocol: 1, SerialNumber: "A2340000001", Name: "SH10RT(COM1-001)", Model: "SH10RT", PortName: "COM1", PhysicalAddress: 1, LogicalAddress: 1},
+			{ID: 2, Code: 4401, Type: 44, Protocol: 1, SerialNumber: "A2340000002", Name: "DTSU666(COM1-254)", Model: "DTSU666", PortName: "COM1", PhysicalAddress: 254, LogicalAddress: 2},
+		},
+		Real: map[int]map[string][]redgiant.RealMeasurement{
+			1: {
+				"real": {
+					{I18NCode: "I18N_COMMON_RUNNING_STATUS", Value: "I18N_COMMON_RUNNING", Unit: ""},
+					{I18NCode: "I18N_COMMON_DAILY_PV_YIELD", Value: "12.3", Unit: "kWh"},
+					{I18NCode: "I18N_COMMON_TOTAL_ACTIVE_POWER", Value: "1.90", Unit: "kW"},
+					{I18NCode: "I18N_COMMON_TOTAL_DCPOWER", Value: "3.10", Unit: "kW"},
+					{I18NCode: "I18N_COMMON_LOAD_TOTAL_ACTIVE_POWER", Value: "1200", Unit: "W"},
+					{I18NCode: "I18N_COMMON_FEED_NETWORK_TOTAL_ACTIVE_POWER", Value: "0.70", Unit: "kW"},
+					{I18NCode: "I18N_CONFIG_KEY_4060", Value: "0.00", Unit: "kW"},
+				},
+				"real_battery": {
+					{I18NCode: "I18N_COMMON_BATTERY_SOC", Value: "87.5", Unit: "%"},
+					{I18NCode: "I18N_COMMON_BATTERY_POWER", Value: "-1.20", Unit: "kW"},
+					{I18NCode: "I18N_CONFIG_KEY_3907", Value: "1.20", Unit: "kW"},
+					{I18NCode: "I18N_CONFIG_KEY_3921", Value: "0.00", Unit: "kW"},
+				},
+			},
+			2: {
+				"real": {
+					{I18NCode: "I18N_COMMON_GRID_FREQUENCY", Value: "50.01", Unit: "Hz"},
+				},
+			},
+		},
+		Direct: map[int]map[string][]redgiant.DirectMeasurement{
+			1: {
+				"direct": {
+					{I18NCode: "I18N_COMMON_PV1_INPUT", Voltage: 412.5, VoltageUnit: "V", Current: 6.2, CurrentUnit: "A"},
+					{I18NCode: "I18N_COMMON_PV2_INPUT", Voltage: 398.1, VoltageUnit: "V", Current: 5.9, CurrentUnit: "A"},
+				},
+			},
+		},
+		Translations: map[redgiant.Language]map[string]string{
+			redgiant.EnglishLanguage: {
+				"I18N_COMMON_RUNNING_STATUS":                  "Running Status",
+				"I18N_COMMON_RUNNING":                         "Running",
+				"I18N_COMMON_DAILY_PV_YIELD":                  "Daily PV Yield",
+				"I18N_COMMON_TOTAL_ACTIVE_POWER":              "Total Active Power",
+				"I18N_COMMON_BATTERY_SOC":                     "Battery Level (SOC)",
+				"I18N_COMMON_BATTERY_POWER":                   "Battery Power",
+				"I18N_COMMON_GRID_FREQUENCY":                  "Grid Frequency",
+				"I18N_COMMON_PV1_INPUT":                       "MPPT1",
+				"I18N_COMMON_PV2_INPUT":                       "MPPT2",
+				"I18N_COMMON_TOTAL_DCPOWER":                   "Total DC Power",
+				"I18N_COMMON_LOAD_TOTAL_ACTIVE_POWER":         "Load Power",
+				"I18N_COMMON_FEED_NETWORK_TOTAL_ACTIVE_POWER": "Feed-in Power",
+				"I18N_CONFIG_KEY_4060":                        "Purchased Power",
+				"I18N_CONFIG_KEY_3907":                        "Battery Charging Power",
+				"I18N_CONFIG_KEY_3921":                        "Battery Discharging Power",
+			},
+			redgiant.GermanLanguage: {
+				"I18N_COMMON_RUNNING_STATUS":                  "Betriebsstatus",
+				"I18N_COMMON_RUNNING":                         "Betrieb",
+				"I18N_COMMON_DAILY_PV_YIELD":                  "Täglicher PV-Ertrag",
+				"I18N_COMMON_TOTAL_ACTIVE_POWER":              "Gesamtwirkleistung",
+				"I18N_COMMON_BATTERY_SOC":                     "Batteriestand (SOC)",
+				"I18N_COMMON_BATTERY_POWER":                   "Batterieleistung",
+				"I18N_COMMON_GRID_FREQUENCY":                  "Netzfrequenz",
+				"I18N_COMMON_PV1_INPUT":                       "MPPT1",
+				"I18N_COMMON_PV2_INPUT":                       "MPPT2",
+				"I18N_COMMON_TOTAL_DCPOWER":                   "Gesamte DC-Leistung",
+				"I18N_COMMON_LOAD_TOTAL_ACTIVE_POWER":         "Lastleistung",
+				"I18N_COMMON_FEED_NETWORK_TOTAL_ACTIVE_POWER": "Einspeiseleistung",
+				"I18N_CONFIG_KEY_4060":                        "Bezugsleistung",
+				"I18N_CONFIG_KEY_3907":                        "Batterieladeleistung",
+				"I18N_CONFIG_KEY_3921":                        "Batterieentladeleistung",
+			},
+		},
+		tokens:  map[string]bool{},
+		results: map[string][]int{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ws/home/overview", inv.serveWebsocket)
+	mux.HandleFunc("GET /about/list", inv.serveAbout)
+	mux.HandleFunc("GET /i18n/{file}", inv.serveTranslations)
+	inv.srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inv.offline.Load() {
+			inv.drop(w)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(inv.srv.Close)
+
+	return inv
+}
+
+// Host returns the address of the inverter, e.g. to pass to redgiant.NewSungrow.
+func (inv *Inverter) Host() string {
+	return inv.srv.Listener.Addr().String()
+}
+
+// Client returns an HTTP client that trusts the certificate of the inverter,
+// e.g. to pass to redgiant.WithHTTPClient.
+func (inv *Inverter) Client() *http.Client {
+	return inv.srv.Client()
+}
+
+// SetOffline makes the inverter drop new connections as if it was unreachable.
+// Established connections are kept.
+func (inv *Inverter) SetOffline(offline bool) {
+	inv.offline.Store(offline)
+}
+
+func (inv *Inverter) drop(w http.ResponseWriter) {
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+			return
+		}
+	}
+	w.WriteHeader(http.StatusServiceUnavailable)
+}
+
+// NewSungrow returns a client for the inverter. It is not connected yet.
+func (inv *Inverter) NewSungrow(opts ...redgiant.OptFunc) *redgiant.Sungrow {
+	return redgiant.NewSungrow(inv.Host(), "", "", append([]redgiant.OptFunc{redgiant.WithHTTPClient(inv.Client()), redgiant.WithLogger(zerolog.Nop())}, opts...)...)
+}
+
+// NewRedgiant starts an emulated inverter, see NewInverter, and returns a
+// client connected to it. opts are passed to redgiant.NewSungrow and
+// redgiant.NewRedgiant. Both are closed at the end of the test.
+func NewRedgiant(t testing.TB, opts ...redgiant.OptFunc) (*redgiant.Redgiant, *Inverter) {
+	t.Helper()
+
+	inv := NewInverter(t)
+	rg := redgiant.NewRedgiant(inv.NewSungrow(opts...), append([]redgiant.OptFunc{redgiant.WithLogger(zerolog.Nop())}, opts...)...)
+	if err := rg.Connect(); err != nil {
+		t.Fatalf("failed to connect to the inverter: %v", err)
+	}
+	t.Cleanup(rg.Close)
+
+	return rg, inv
+}
+
+type response struct {
+	Code    int    `json:"result_code"`
+	Message string `json:"result_msg"`
+	Data    any    `json:"result_data"`
+}
+
+func success(data any) response {
+	return response{Code: 1, Message: "success", Data: data}
+}
+
+func (inv *Inverter) validToken(token string) bool {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	return inv.tokens[token]
+}
+
+func (inv *Inverter) newToken() string {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	token := strings.ReplaceAll(uuid.NewString(), "-", "")
+	inv.tokens[token] = true
+	return token
+}
+
+func (inv *Inverter) serveWebsocket(w http.ResponseWriter, r *http.Request) {
+	ws, err := inv.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	for {
+		var m map[string]any
+		if err := ws.ReadJSON(&m); err != nil {
+			return
+		}
+		if err := ws.WriteJSON(inv.handle(m)); err != nil {
+			return
+		}
+	}
+}
+
+func (inv *Inverter) handle(m map[string]any) response {
+	service, _ := m["service"].(string)
+	token, _ := m["token"].(string)
+
+	switch service {
+	case "connect", "login":
+		return success(map[string]any{"service": service, "token": inv.newToken()})
+	case "ping":
+		return success(map[string]any{})
+	}
+
+	if !inv.validToken(token) {
+		return response{Code: 106, Message: "token invalid", Data: map[string]any{"service": service}}
+	}
+
+	data := map[string]any{"service": service}
+	if code, ok := inv.nextResult(service); ok {
+		return response{Code: code, Message: "error", Data: data}
+	}
+
+	deviceID, _ := strconv.Atoi(fmt.Sprint(m["dev_id"]))
+	switch service {
+	case "state":
+		data["total_fault"] = strconv.Itoa(inv.State.TotalFaults)
+		data["total_alarm"] = strconv.Itoa(inv.State.TotalAlarms)
+		data["wireless_conn_sts"] = boolInt(inv.State.WirelessConnection)
+		data["wifi_conn_sts"] = boolInt(inv.State.WifiConnection)
+		data["eth_conn_sts"] = boolInt(inv.State.Ethernet1Connection)
+		data["eth2_conn_sts"] = boolInt(inv.State.Ethernet2Connection)
+		data["cloud_conn_sts"] = boolInt(inv.State.CloudConnection)
+	case "devicelist":
+		list := make([]map[string]any, 0, len(inv.Devices))
+		for _, d := range inv.Devices {
+			list = append(list, map[string]any{
+				"dev_id":       d.ID,
+				"dev_code":     d.Code,
+				"dev_type":     d.Type,
+				"dev_protocol": d.Protocol,
+				"dev_sn":       d.SerialNumber,
+				"dev_name":     d.Name,
+				"dev_model":    d.Model,
+				"dev_special":  d.Special,
+				"inv_type":     d.InvType,
+				"port_name":    d.PortName,
+				"phys_addr":    strconv.Itoa(d.PhysicalAddress),
+				"logc_addr":    strconv.Itoa(d.LogicalAddress),
+				"link_status":  d.LinkStatus,
+				"init_status":  d.InitStatus,
+			})
+		}
+		data["list"] = list
+	default:
+		if ms, ok := inv.Real[deviceID][service]; ok {
+			list := make([]map[string]any, 0, len(ms))
+			for _, m := range ms {
+				list = append(list, map[string]any{"data_name": m.I18NCode, "data_value": m.Value, "data_unit": m.Unit})
+			}
+			data["list"] = list
+		} else if ms, ok := inv.Direct[deviceID][service]; ok {
+			list := make([]map[string]any, 0, len(ms))
+			for _, m := range ms {
+				list = append(list, map[string]any{
+					"name":         m.I18NCode,
+					"voltage":      strconv.FormatFloat(float64(m.Voltage), 'f', -1, 32),
+					"voltage_unit": m.VoltageUnit,
+					"current":      strconv.FormatFloat(float64(m.Current), 'f', -1, 32),
+					"current_unit": m.CurrentUnit,
+				})
+			}
+			data["list"] = list
+		} else {
+			return response{Code: 2, Message: "unknown service", Data: data}
+		}
+	}
+	return success(data)
+}
+
+// QueueResults makes the inverter answer the next requests of the service with
+// the result codes instead of the data, one per request.
+func (inv *Inverter) QueueResults(service string, codes ...int) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	inv.results[service] = append(inv.results[service], codes...)
+}
+
+func (inv *Inverter) nextResult(service string) (int, bool) {
+	inv.mu.Lock()
+	defer inv.mu.Unlock()
+	codes := inv.results[service]
+	if len(codes) == 0 {
+		return 0, false
+	}
+	inv.results[service] = codes[1:]
+	return codes[0], true
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (inv *Inverter) serveAbout(w http.ResponseWriter, r *http.Request) {
+	var resp response
+	if !inv.validToken(r.URL.Query().Get("token")) {
+		resp = response{Code: 106, Message: "token invalid"}
+	} else {
+		list := []map[string]string{}
+		for code, value := range map[string]string{
+			"I18N_COMMON_DEVICE_SN":          inv.About.SerialNumber,
+			"I18N_COMMON_VERSION":            inv.About.Version,
+			"I18N_COMMON_APPLI_SOFT_VERSION": inv.About.SoftwareVersion,
+			"I18N_COMMON_BUILD_SOFT_VERSION": inv.About.BuildVersion,
+		} {
+			list = append(list, map[string]string{"data_name": code, "data_value": value})
+		}
+		resp = success(map[string]any{"list": list})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (inv *Inverter) serveTranslations(w http.ResponseWriter, r *http.Request) {
+	lang, ok := strings.CutSuffix(r.PathValue("file"), ".properties")
+	translations, found := inv.Translations[redgiant.Language(lang)]
+	if !ok || !found {
+		http.NotFound(w, r)
+		return
+	}
+	properties.Write(w, translations)
+}
@@ -0,0 +1,117 @@
+package redgiant
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/pmeier/redgiant/internal/errors"
+)
+
+// ResultPolicy is how a result code of the inverter is handled.
+type ResultPolicy uint8
+
+const (
+	// FailResultPolicy returns an InverterError right away.
+	FailResultPolicy ResultPolicy = iota
+	// ReconnectResultPolicy reconnects and sends the request again.
+	ReconnectResultPolicy
+	// RetryResultPolicy sends the request again after a delay, see
+	// WithResultRetries. No result code is retried by default, see
+	// WithResultPolicies.
+	RetryResultPolicy
+)
+
+func (p ResultPolicy) String() string {
+	switch p {
+	case FailResultPolicy:
+		return "fail"
+	case ReconnectResultPolicy:
+		return "reconnect"
+	case RetryResultPolicy:
+		return "retry"
+	}
+	return strconv.Itoa(int(p))
+}
+
+const successResultCode = 1
+
+type resultCode struct {
+	meaning string
+	policy  ResultPolicy
+}
+
+// resultCodes are the result codes of the inverter other than success that
+// were observed in the wild. Unknown codes fail. 103 is sent by the inverter
+// on its own when the session of the web interface timed out and is dropped
+// rather than returned, see responseCodesToBeDropped.
+var resultCodes = map[int]resultCode{
+	100: {"token expired", ReconnectResultPolicy},
+	104: {"not logged in", ReconnectResultPolicy},
+	106: {"token invalid", ReconnectResultPolicy},
+}
+
+// ResultCodeMeaning returns what a result code of the inverter means, or an
+// empty string if the code is unknown.
+func ResultCodeMeaning(code int) string {
+	return resultCodes[code].meaning
+}
+
+// ResultCodePolicy returns how a result code of the inverter is handled by
+// default.
+func ResultCodePolicy(code int) ResultPolicy {
+	return resultCodes[code].policy
+}
+
+// InverterError is returned if the inverter answers a request with a result
+// code other than success.
+type InverterError struct {
+	*errors.RedgiantError
+	ResultCode    int
+	ResultMessage string
+	// Service is the service of a websocket request or the path of an HTTP
+	// request.
+	Service string
+	Data    json.RawMessage
+	policy  ResultPolicy
+}
+
+// Unwrap makes the RedgiantError visible to errors.Is and errors.As.
+func (e *InverterError) Unwrap() error {
+	return e.RedgiantError
+}
+
+// Meaning returns what the result code means, or an empty string if it is
+// unknown.
+func (e *InverterError) Meaning() string {
+	return ResultCodeMeaning(e.ResultCode)
+}
+
+// Policy returns how the result code was handled.
+func (e *InverterError) Policy() ResultPolicy {
+	return e.policy
+}
+
+func newInverterError(service string, r *Response, policy ResultPolicy) *InverterError {
+	msg := fmt.Sprintf("inverter returned result code %d", r.Code)
+	if meaning := ResultCodeMeaning(r.Code); meaning != "" {
+		msg += " (" + meaning + ")"
+	}
+
+	return &InverterError{
+		RedgiantError: errors.New(
+			msg,
+			errors.WithCode(errors.InverterErrorCode),
+			errors.WithFault(errors.InverterFault),
+			errors.WithTransient(policy != FailResultPolicy),
+			errors.WithContext(errors.Context{"service": service, "resultCode": r.Code, "resultMessage": r.Message}),
+			errors.WithHTTPDetail(errors.ContextHTTPDetail),
+			errors.WithHiddenFrames(2),
+		),
+		ResultCode:    r.Code,
+		ResultMessage: r.Message,
+		Service:       service,
+		Data:          r.Data,
+		policy:        policy,
+	}
+}
@@ -0,0 +1,51 @@
+package redgiant_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/redgianttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCodes(t *testing.T) {
+	busy := redgiant.WithResultPolicies(map[int]redgiant.ResultPolicy{105: redgiant.RetryResultPolicy})
+
+	tests := []struct {
+		name           string
+		opts           []redgiant.OptFunc
+		codes          []int
+		resultCode     int
+		expectedPolicy redgiant.ResultPolicy
+	}{
+		{name: "token expired", codes: []int{100}},
+		{name: "not logged in", codes: []int{104}},
+		{name: "token invalid", codes: []int{106}},
+		{name: "retried", opts: []redgiant.OptFunc{busy}, codes: []int{105, 105}},
+		{name: "retries exhausted", opts: []redgiant.OptFunc{busy}, codes: []int{105, 105, 105, 105}, resultCode: 105, expectedPolicy: redgiant.RetryResultPolicy},
+		{name: "unknown", codes: []int{42}, resultCode: 42, expectedPolicy: redgiant.FailResultPolicy},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rg, inv := redgianttest.NewRedgiant(t, append([]redgiant.OptFunc{redgiant.WithResultRetries(3, time.Millisecond)}, test.opts...)...)
+
+			inv.QueueResults("state", test.codes...)
+			s, err := rg.State()
+			if test.resultCode == 0 {
+				require.NoError(t, err)
+				assert.Equal(t, inv.State, s)
+				return
+			}
+
+			var ie *redgiant.InverterError
+			require.True(t, errors.As(err, &ie))
+			assert.Equal(t, test.resultCode, ie.ResultCode)
+			assert.Equal(t, "state", ie.Service)
+			assert.Equal(t, test.expectedPolicy, ie.Policy())
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package redgiant
+
+import (
+	"cmp"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/pmeier/redgiant/internal/errors"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Cataloger is implemented by Localizers that can list all their translations,
+// which enables reverse lookups.
+type Cataloger interface {
+	Catalog(lang Language) (map[string]string, error)
+}
+
+// minSearchScore is the minimum score of search results.
+const minSearchScore = 0.6
+
+type SearchResult struct {
+	I18NCode string `json:"i18nCode"`
+	// Score rates how well the query matched, from 0 to 1 for an exact match.
+	Score float64 `json:"score"`
+	// Names are the names of the code in the searched languages.
+	Names map[Language]string `json:"names"`
+}
+
+// Lookup returns the i18n code of the name. The comparison ignores case,
+// diacritics and punctuation.
+func Lookup(c Cataloger, name string, lang Language) (string, error) {
+	catalog, err := c.Catalog(lang)
+	if err != nil {
+		return "", err
+	}
+
+	normalized := normalizeName(name)
+	// iterate in order, so ambiguous names resolve deterministically
+	for _, i18nCode := range slices.Sorted(maps.Keys(catalog)) {
+		if normalizeName(catalog[i18nCode]) == normalized {
+			return i18nCode, nil
+		}
+	}
+	return "", errors.New(
+		"unknown name",
+		errors.WithCode(errors.UnknownNameCode),
+		errors.WithContext(errors.Context{"name": name, "language": lang.String()}),
+		errors.WithHTTPCode(http.StatusUnprocessableEntity),
+		errors.WithFault(errors.ClientFault),
+		errors.WithHTTPDetail(errors.ContextHTTPDetail),
+	)
+}
+
+// Search returns the i18n codes whose names in any of the languages or the
+// codes themselves fuzzily match the query, best match first.
+func Search(c Cataloger, query string, langs ...Language) ([]SearchResult, error) {
+	query = normalizeName(query)
+	if query == "" {
+		return []SearchResult{}, nil
+	}
+
+	results := map[string]*SearchResult{}
+	for _, lang := range langs {
+		catalog, err := c.Catalog(lang)
+		if err != nil {
+			return nil, err
+		}
+
+		for i18nCode, name := range catalog {
+			score := max(matchScore(query, normalizeName(name)), matchScore(query, normalizeCode(i18nCode)))
+
+			r, ok := results[i18nCode]
+			if !ok {
+				r = &SearchResult{I18NCode: i18nCode, Names: map[Language]string{}}
+				results[i18nCode] = r
+			}
+			r.Names[lang] = name
+			r.Score = max(r.Score, score)
+		}
+	}
+
+	matches := []SearchResult{}
+	for _, r := range results {
+		if r.Score >= minSearchScore {
+			matches = append(matches, *r)
+		}
+	}
+	slices.SortFunc(matches, func(a, b SearchResult) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.I18NCode, b.I18NCode))
+	})
+	return matches, nil
+}
+
+var removeDiacritics = transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+
+// normalizeName lowercases the name, strips diacritics and replaces everything
+// but letters and digits with single spaces.
+func normalizeName(name string) string {
+	if s, _, err := transform.String(removeDiacritics, name); err == nil {
+		name = s
+	}
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}), " ")
+}
+
+// normalizeCode turns e.g. I18N_COMMON_BATTERY_SOC into "common battery soc".
+func normalizeCode(i18nCode string) string {
+	return normalizeName(strings.TrimPrefix(i18nCode, "I18N_"))
+}
+
+// matchScore rates how well the normalized query matches the normalized
+// candidate from 0 to 1.
+func matchScore(query string, candidate string) float64 {
+	switch {
+	case candidate == query:
+		return 1
+	case strings.HasPrefix(candidate, query):
+		return 0.9
+	case strings.Contains(candidate, query):
+		return 0.8
+	}
+
+	// every word of the query should match some word of the candidate, which
+	// tolerates typos and a different word order
+	cws := strings.Fields(candidate)
+	var total float64
+	for _, qw := range strings.Fields(query) {
+		var best float64
+		for _, cw := range cws {
+			best = max(best, wordScore(qw, cw))
+		}
+		total += best
+	}
+	words := total / float64(len(strings.Fields(query)))
+
+	return max(0.85*words, similarity(query, candidate))
+}
+
+func wordScore(query string, candidate string) float64 {
+	if strings.HasPrefix(candidate, query) {
+		// prefixes need some substance to count, e.g. "bat" for "battery"
+		return min(1, float64(len(query))/3) * 0.95
+	}
+	return similarity(query, candidate)
+}
+
+// similarity is one minus the edit distance relative to the longer string.
+func similarity(a string, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	n := max(len(ra), len(rb))
+	if n == 0 {
+		return 1
+	}
+	return 1 - float64(levenshtein(ra, rb))/float64(n)
+}
+
+func levenshtein(a []rune, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,95 @@
+package redgiant
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCataloger serves made-up translations that only exercise the matching.
+type testCataloger map[Language]map[string]string
+
+func (c testCataloger) Catalog(lang Language) (map[string]string, error) {
+	catalog, ok := c[lang]
+	if !ok {
+		return nil, errors.New("unknown language")
+	}
+	return catalog, nil
+}
+
+var testCatalogs = testCataloger{
+	EnglishLanguage: {
+		"I18N_COMMON_BATTERY_SOC":         "Battery Level (SOC)",
+		"I18N_COMMON_BATTERY_SOH":         "Battery Health (SOH)",
+		"I18N_COMMON_BATTERY_TEMPERATURE": "Battery Temperature",
+		"I18N_COMMON_DAILY_PV_YIELD":      "Daily PV Yield",
+		"I18N_COMMON_GRID_FREQUENCY":      "Grid Frequency",
+	},
+	GermanLanguage: {
+		"I18N_COMMON_BATTERY_SOC":         "Batteriestand (SOC)",
+		"I18N_COMMON_BATTERY_SOH":         "Batteriezustand (SOH)",
+		"I18N_COMMON_BATTERY_TEMPERATURE": "Batterietemperatur",
+		"I18N_COMMON_DAILY_PV_YIELD":      "Täglicher PV-Ertrag",
+		"I18N_COMMON_GRID_FREQUENCY":      "Netzfrequenz",
+	},
+	ChineseLanguage: {
+		"I18N_COMMON_BATTERY_SOC":         "电池电量(SOC)",
+		"I18N_COMMON_BATTERY_TEMPERATURE": "电池温度",
+		"I18N_COMMON_GRID_FREQUENCY":      "电网频率",
+	},
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		name     string
+		lang     Language
+		expected string
+	}{
+		{name: "Battery Level (SOC)", lang: EnglishLanguage, expected: "I18N_COMMON_BATTERY_SOC"},
+		{name: "battery level soc", lang: EnglishLanguage, expected: "I18N_COMMON_BATTERY_SOC"},
+		{name: "Taglicher PV-Ertrag", lang: GermanLanguage, expected: "I18N_COMMON_DAILY_PV_YIELD"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual, err := Lookup(testCatalogs, test.name, test.lang)
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+
+	_, err := Lookup(testCatalogs, "Battery", EnglishLanguage)
+	assert.Error(t, err)
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		query    string
+		langs    []Language
+		expected string
+	}{
+		{query: "Battery Level", langs: []Language{EnglishLanguage}, expected: "I18N_COMMON_BATTERY_SOC"},
+		{query: "Batteriestand", langs: []Language{EnglishLanguage, GermanLanguage}, expected: "I18N_COMMON_BATTERY_SOC"},
+		{query: "batery levl", langs: []Language{EnglishLanguage}, expected: "I18N_COMMON_BATTERY_SOC"},
+		{query: "level battery", langs: []Language{EnglishLanguage}, expected: "I18N_COMMON_BATTERY_SOC"},
+		{query: "netzfrequenz", langs: []Language{GermanLanguage}, expected: "I18N_COMMON_GRID_FREQUENCY"},
+		{query: "BATTERY_SOH", langs: []Language{EnglishLanguage}, expected: "I18N_COMMON_BATTERY_SOH"},
+		{query: "电池温度", langs: []Language{ChineseLanguage}, expected: "I18N_COMMON_BATTERY_TEMPERATURE"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			results, err := Search(testCatalogs, test.query, test.langs...)
+			require.NoError(t, err)
+			require.NotEmpty(t, results)
+			assert.Equal(t, test.expected, results[0].I18NCode)
+			assert.Len(t, results[0].Names, len(test.langs))
+		})
+	}
+
+	results, err := Search(testCatalogs, "xyzzy", EnglishLanguage)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+}
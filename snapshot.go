@@ -0,0 +1,104 @@
+package redgiant
+
+import (
+	"time"
+
+	"github.com/pmeier/redgiant/internal/errors"
+)
+
+// Snapshot reads the data of all devices in one pass. The measurements of the
+// services of the device types are localized in the language. Services that
+// cannot be read are reported per device instead of failing the snapshot,
+// which only fails if the inverter, its state or its devices cannot be read.
+//
+// The inverter answers requests over its websocket one at a time, so only the
+// information about the inverter, which is served over HTTP, is read
+// concurrently.
+func (rg *Redgiant) Snapshot(lang Language) (Snapshot, error) {
+	rg.log.Trace().Stringer("lang", lang).Msg("Redgiant.Snapshot()")
+
+	if err := rg.acquire(); err != nil {
+		return Snapshot{}, err
+	}
+
+	s := Snapshot{Time: time.Now()}
+
+	type aboutResult struct {
+		about About
+		err   error
+	}
+	aboutCh := make(chan aboutResult, 1)
+	go func() {
+		a, err := rg.cachedAbout()
+		aboutCh <- aboutResult{about: a, err: err}
+	}()
+
+	state, err := rg.State()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	s.State = state
+
+	devices, err := rg.Devices()
+	if err != nil {
+		return Snapshot{}, err
+	}
+
+	// the serial number of the measurements is read from the cache afterwards
+	ar := <-aboutCh
+	if ar.err != nil {
+		return Snapshot{}, ar.err
+	}
+	s.About = ar.about
+
+	s.Devices = make([]DeviceSnapshot, 0, len(devices))
+	for _, d := range devices {
+		s.Devices = append(s.Devices, rg.deviceSnapshot(d, lang))
+	}
+	return s, nil
+}
+
+func (rg *Redgiant) deviceSnapshot(d Device, lang Language) DeviceSnapshot {
+	ds := DeviceSnapshot{Device: d, Real: []RealMeasurement{}, Direct: []DirectMeasurement{}}
+
+	realServices, realOK := availableRealDataServices[d.Type]
+	directServices, directOK := availableDirectDataServices[d.Type]
+	if !realOK && !directOK {
+		ds.Errors = append(ds.Errors, SnapshotError{
+			Code:    string(errors.UnknownDeviceTypeCode),
+			Message: "unknown device type",
+		})
+		return ds
+	}
+
+	for _, service := range realServices {
+		ms, err := rg.RealData(d.ID, lang, service)
+		if err != nil {
+			ds.Errors = append(ds.Errors, newSnapshotError(service, err))
+			continue
+		}
+		ds.Real = append(ds.Real, ms...)
+	}
+	for _, service := range directServices {
+		ms, err := rg.DirectData(d.ID, lang, service)
+		if err != nil {
+			ds.Errors = append(ds.Errors, newSnapshotError(service, err))
+			continue
+		}
+		ds.Direct = append(ds.Direct, ms...)
+	}
+
+	if len(ds.Errors) > 0 {
+		rg.log.Debug().Int("deviceID", d.ID).Any("errors", ds.Errors).Msg("incomplete device snapshot")
+	}
+	return ds
+}
+
+func newSnapshotError(service string, err error) SnapshotError {
+	se := SnapshotError{Service: service, Message: err.Error()}
+	var rge *errors.RedgiantError
+	if errors.As(err, &rge) {
+		se.Code = string(rge.Code())
+	}
+	return se
+}
@@ -0,0 +1,31 @@
+package redgiant_test
+
+import (
+	"testing"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/redgianttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshotPartialFailure(t *testing.T) {
+	rg, inv := redgianttest.NewRedgiant(t)
+
+	inv.QueueResults("real_battery", 42)
+	s, err := rg.Snapshot(redgiant.NoLanguage)
+	require.NoError(t, err)
+	require.Len(t, s.Devices, 2)
+
+	ds := s.Devices[0]
+	require.Len(t, ds.Errors, 1)
+	assert.Equal(t, "real_battery", ds.Errors[0].Service)
+	assert.Equal(t, "inverter-error", ds.Errors[0].Code)
+	assert.Len(t, ds.Real, len(inv.Real[1]["real"]))
+	for _, m := range ds.Real {
+		assert.Equal(t, "real", m.Service)
+	}
+	assert.Len(t, ds.Direct, len(inv.Direct[1]["direct"]))
+
+	assert.Empty(t, s.Devices[1].Errors)
+}
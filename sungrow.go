@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -38,22 +39,55 @@ type SungrowDisconnectedError struct {
 	*errors.RedgiantError
 }
 
+// Unwrap makes the RedgiantError and thus its cause visible to errors.Is and
+// errors.As.
+func (e *SungrowDisconnectedError) Unwrap() error {
+	return e.RedgiantError
+}
+
 func newSungrowDisconnectedError(msg string) error {
-	return &SungrowDisconnectedError{RedgiantError: errors.New(msg, errors.WithHiddenFrames(2))}
+	return &SungrowDisconnectedError{RedgiantError: errors.New(
+		msg,
+		errors.WithHTTPCode(http.StatusServiceUnavailable),
+		errors.WithFault(errors.InverterFault),
+		errors.WithTransient(true),
+		errors.WithHiddenFrames(2),
+	)}
+}
+
+// wrapSungrowDisconnectedError keeps err, e.g. a network error, as cause.
+func wrapSungrowDisconnectedError(err error) error {
+	return &SungrowDisconnectedError{RedgiantError: errors.Wrap(
+		err,
+		errors.WithHTTPCode(http.StatusServiceUnavailable),
+		errors.WithFault(errors.InverterFault),
+		errors.WithTransient(true),
+		errors.WithHiddenFrames(2),
+	).(*errors.RedgiantError)}
 }
 
 type Sungrow struct {
-	Host            string
-	Username        string
-	Password        string
-	log             zerolog.Logger
-	c               *http.Client
-	mu              sync.Mutex
-	ws              *websocket.Conn
+	Host     string
+	Username string
+	Password string
+	log      zerolog.Logger
+	c        *http.Client
+	// mu serializes the requests over ws and guards it.
+	mu        sync.Mutex
+	connectMu sync.Mutex
+	ws        *websocket.Conn
+	// sessionMu guards connected and token, which are changed while holding
+	// mu as well. Unlike mu, it is not held while waiting for the inverter.
+	sessionMu       sync.Mutex
 	connected       bool
 	token           string
 	cancelHeartbeat context.CancelFunc
 	reconnectTries  uint
+	resultPolicies  map[int]ResultPolicy
+	resultRetries   uint
+	resultRetryWait time.Duration
+	lastHeartbeat   atomic.Int64
+	lastRead        atomic.Int64
 }
 
 func NewSungrow(host string, username string, password string, opts ...OptFunc) *Sungrow {
@@ -66,8 +100,19 @@ func NewSungrow(host string, username string, password string, opts ...OptFunc)
 			Timeout: time.Second * 60,
 		}),
 		WithReconnect(3),
+		WithResultRetries(3, time.Second),
 	}, opts...)...)
-	return &Sungrow{Host: host, Username: username, Password: password, c: o.HTTPClient, log: o.Logger, reconnectTries: o.ReconnectTries}
+	return &Sungrow{
+		Host:            host,
+		Username:        username,
+		Password:        password,
+		c:               o.ApplyTLSConfig(),
+		log:             o.Logger,
+		reconnectTries:  o.ReconnectTries,
+		resultPolicies:  o.ResultPolicies,
+		resultRetries:   o.ResultRetries,
+		resultRetryWait: o.ResultRetryDelay,
+	}
 }
 
 func (s *Sungrow) Connect() error {
@@ -75,7 +120,12 @@ func (s *Sungrow) Connect() error {
 
 	log := s.log.With().Str("host", s.Host).Logger()
 
-	if s.connected {
+	// Connecting happens in the background as well as on demand when the
+	// connection is lost, so concurrent attempts have to be serialized.
+	s.connectMu.Lock()
+	defer s.connectMu.Unlock()
+
+	if connected, _ := s.session(); connected {
 		log.Debug().Msg("already connected")
 		return nil
 	}
@@ -94,7 +144,9 @@ func (s *Sungrow) Connect() error {
 	if err != nil {
 		return errors.Wrap(err)
 	}
+	s.mu.Lock()
 	s.ws = ws
+	s.mu.Unlock()
 
 	type data struct {
 		Token string `json:"token"`
@@ -107,22 +159,73 @@ func (s *Sungrow) Connect() error {
 	if err != nil {
 		return err
 	}
-	s.connected = true
 
 	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	if s.ws != ws {
+		// closed while connecting
+		s.mu.Unlock()
+		cancel()
+		return wrapSungrowDisconnectedError(errClosed)
+	}
+	s.setSession(true, "")
 	s.cancelHeartbeat = cancel
+	s.mu.Unlock()
 	go s.heartbeat(ctx)
 
 	err = s.Send("login", map[string]any{"token": d.Token, "username": "user", "passwd": s.Password}, &d)
 	if err != nil {
 		return err
 	}
-	s.token = d.Token
+	s.mu.Lock()
+	if s.ws == ws {
+		s.setSession(true, d.Token)
+	}
+	s.mu.Unlock()
 
 	log.Info().Msg("connected")
 	return nil
 }
 
+func (s *Sungrow) IsConnected() bool {
+	connected, token := s.session()
+	return connected && token != ""
+}
+
+// session returns whether the connection is established and the token of the
+// login, if any.
+func (s *Sungrow) session() (bool, string) {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	return s.connected, s.token
+}
+
+// setSession has to be called while holding mu, so requests do not observe a
+// session that does not match ws.
+func (s *Sungrow) setSession(connected bool, token string) {
+	s.sessionMu.Lock()
+	defer s.sessionMu.Unlock()
+	s.connected = connected
+	s.token = token
+}
+
+func unixNanoTime(ns int64) time.Time {
+	if ns == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, ns)
+}
+
+// LastHeartbeat returns the time of the last heartbeat answered by the inverter.
+func (s *Sungrow) LastHeartbeat() time.Time {
+	return unixNanoTime(s.lastHeartbeat.Load())
+}
+
+// LastRead returns the time data was last read successfully from the inverter.
+func (s *Sungrow) LastRead() time.Time {
+	return unixNanoTime(s.lastRead.Load())
+}
+
 func (s *Sungrow) heartbeat(ctx context.Context) {
 	ticker := time.NewTicker(time.Second * 3)
 	for {
@@ -138,33 +241,48 @@ func (s *Sungrow) heartbeat(ctx context.Context) {
 	}
 }
 
+// closeTimeout limits how long Close waits for the inverter to answer the closing handshake.
+const closeTimeout = 5 * time.Second
+
 func (s *Sungrow) Close() {
 	s.log.Trace().Msg("Sungrow.Close()")
 
-	if s.ws == nil {
-		s.log.Debug().Msg("already disconnected")
-		return
-	}
-
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.token = ""
 	if s.cancelHeartbeat != nil {
 		s.cancelHeartbeat()
+		s.cancelHeartbeat = nil
 	}
-	s.connected = false
 
-	wmt := websocket.CloseMessage
-	if err := s.ws.WriteMessage(wmt, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")); err != nil {
+	if s.ws == nil {
+		s.log.Debug().Msg("already disconnected")
+		return
+	}
+
+	s.setSession(false, "")
+	defer func() {
+		s.ws.Close()
+		s.ws = nil
+	}()
+
+	deadline := time.Now().Add(closeTimeout)
+	msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+	if err := s.ws.WriteControl(websocket.CloseMessage, msg, deadline); err != nil {
 		s.log.Debug().Msg("connection closed by server")
 		return
 	}
-	rmt, _, err := s.ws.ReadMessage()
-	if err != nil {
-		s.log.Debug().Err(err).Msg("no closing message from server")
-	} else if rmt != wmt {
-		s.log.Debug().Int("write", wmt).Int("read", rmt).Msg("closing handshake message type mismatch")
+
+	// Wait for the inverter to echo the close frame. Data messages that were
+	// still in flight are dropped.
+	s.ws.SetReadDeadline(deadline)
+	for {
+		if _, _, err := s.ws.ReadMessage(); err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				s.log.Debug().Err(err).Msg("no closing message from server")
+			}
+			break
+		}
 	}
 
 	s.log.Info().Str("host", s.Host).Msg("disconnected")
@@ -189,34 +307,47 @@ func (s *Sungrow) reconnect() error {
 func (s *Sungrow) Get(path string, params map[string]string, v any) error {
 	s.log.Trace().Str("path", path).Any("params", params).Any("v", v).Msg("Sungrow.Get()")
 
-	if s.token == "" {
-		return errors.New("not connected")
+	if _, token := s.session(); token == "" {
+		return newSungrowDisconnectedError("not connected")
 	}
 
 	u := url.URL{Scheme: "https", Host: s.Host, Path: path}
 	q := u.Query()
 	q.Set("lang", "zh_cn")
-	q.Set("token", s.token)
 	q.Set("page", "1")
 	q.Set("limit", "10")
 	for k, v := range params {
 		q.Set(k, v)
 	}
-	u.RawQuery = q.Encode()
 
+	retries := 0
 	for {
+		// the token changes when reconnecting
+		_, token := s.session()
+		q.Set("token", token)
+		u.RawQuery = q.Encode()
+
 		r, err := s.get(u)
-		switch err.(type) {
-		case SungrowDisconnectedError:
+		var de *SungrowDisconnectedError
+		if errors.As(err, &de) {
 			if err := s.reconnect(); err != nil {
 				return err
 			}
 			continue
-		case error:
+		} else if err != nil {
+			return err
+		}
+		if again, err := s.handleResult(path, r, s.reconnect, &retries); again {
+			continue
+		} else if err != nil {
 			return err
 		}
 
-		return json.Unmarshal(r.Data, v)
+		if err := json.Unmarshal(r.Data, v); err != nil {
+			return err
+		}
+		s.lastRead.Store(time.Now().UnixNano())
+		return nil
 	}
 }
 
@@ -228,7 +359,7 @@ func (s *Sungrow) get(u url.URL) (*Response, error) {
 
 	r, err := s.c.Get(u.String())
 	if err != nil {
-		return nil, newSungrowDisconnectedError(err.Error())
+		return nil, wrapSungrowDisconnectedError(err)
 	}
 	defer r.Body.Close()
 
@@ -245,8 +376,8 @@ func (s *Sungrow) get(u url.URL) (*Response, error) {
 func (s *Sungrow) Send(service string, params map[string]any, v any) error {
 	s.log.Trace().Str("service", service).Any("params", params).Msg("Sungrow.Send()")
 
-	if (!s.connected && service != "connect") || (s.connected && s.token == "" && service != "login") {
-		return errors.New("not connected")
+	if !s.sendable(service) {
+		return newSungrowDisconnectedError("not connected")
 	}
 	reconnect := func() error {
 		if service == "connect" || service == "login" {
@@ -257,22 +388,29 @@ func (s *Sungrow) Send(service string, params map[string]any, v any) error {
 
 	m := map[string]any{
 		"lang":    "zh_cn",
-		"token":   s.token,
 		"service": service,
 	}
 	for k, v := range params {
 		m[k] = v
 	}
 
+	retries := 0
 	for {
+		// the token changes when reconnecting
+		if _, ok := params["token"]; !ok {
+			_, token := s.session()
+			m["token"] = token
+		}
 		resp, err := s.send(service, m)
-		switch err.(type) {
-		case *SungrowDisconnectedError:
+		var de *SungrowDisconnectedError
+		if errors.Is(err, errClosed) {
+			return err
+		} else if errors.As(err, &de) {
 			if err := reconnect(); err != nil {
 				return err
 			}
 			continue
-		case error:
+		} else if err != nil {
 			return errors.Wrap(err)
 		}
 
@@ -281,22 +419,55 @@ func (s *Sungrow) Send(service string, params map[string]any, v any) error {
 			d = string(resp.Data)
 		}
 
-		switch resp.Code {
-		case 1:
-			if service == "ping" {
-				return nil
-			}
+		if again, err := s.handleResult(service, resp, reconnect, &retries); again {
+			continue
+		} else if err != nil {
+			return err
+		}
 
+		switch service {
+		case "ping":
+			s.lastHeartbeat.Store(time.Now().UnixNano())
+			return nil
+		case "connect", "login":
 			return json.Unmarshal(resp.Data, v)
-		case 100, 104, 106:
-			if err := reconnect(); err != nil {
-				return err
-			}
-			continue
-		default:
-			return errors.New("unknown server error")
+		}
+
+		if err := json.Unmarshal(resp.Data, v); err != nil {
+			return err
+		}
+		s.lastRead.Store(time.Now().UnixNano())
+		return nil
+	}
+}
+
+// handleResult applies the policy of the result code of r. It reports whether
+// the request has to be sent again.
+func (s *Sungrow) handleResult(service string, r *Response, reconnect func() error, retries *int) (bool, error) {
+	if r.Code == successResultCode {
+		return false, nil
+	}
+
+	policy, ok := s.resultPolicies[r.Code]
+	if !ok {
+		policy = ResultCodePolicy(r.Code)
+	}
+	ie := newInverterError(service, r, policy)
+	s.log.Debug().Err(ie).Str("policy", ie.Policy().String()).Msg("inverter returned an error")
+	switch ie.Policy() {
+	case ReconnectResultPolicy:
+		if err := reconnect(); err != nil {
+			return false, err
+		}
+		return true, nil
+	case RetryResultPolicy:
+		if *retries < int(s.resultRetries) {
+			*retries++
+			time.Sleep(s.resultRetryWait)
+			return true, nil
 		}
 	}
+	return false, ie
 }
 
 var responseCodesToBeDropped = []int{
@@ -304,20 +475,38 @@ var responseCodesToBeDropped = []int{
 	103,
 }
 
+// errClosed is the cause of the error of requests that were sent while or
+// after the connection was closed. Unlike a lost connection, it does not
+// trigger a reconnect.
+var errClosed = errors.New("connection closed")
+
+// sendable reports whether the service can be sent in the current session.
+// Only connect is sent before the connection is established and only login
+// before the login.
+func (s *Sungrow) sendable(service string) bool {
+	connected, token := s.session()
+	return (connected || service == "connect") && (token != "" || !connected || service == "login")
+}
+
 func (s *Sungrow) send(service string, m map[string]any) (*Response, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	s.log.Trace().Str("service", service).Any("m", m).Msg("Sungrow.send()")
 
+	// Close might have run since the session was checked.
+	if s.ws == nil || !s.sendable(service) {
+		return nil, wrapSungrowDisconnectedError(errClosed)
+	}
+
 	if err := s.ws.WriteJSON(m); err != nil {
-		return nil, newSungrowDisconnectedError(err.Error())
+		return nil, wrapSungrowDisconnectedError(err)
 	}
 
 	var r Response
 	for {
 		if err := s.ws.ReadJSON(&r); err != nil {
-			return nil, newSungrowDisconnectedError(err.Error())
+			return nil, wrapSungrowDisconnectedError(err)
 		}
 		s.log.Trace().EmbedObject(r).Msg("read message")
 
@@ -0,0 +1,40 @@
+package redgiant_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/pmeier/redgiant"
+	"github.com/pmeier/redgiant/redgianttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSungrowCloseWhileSending(t *testing.T) {
+	sg := redgianttest.NewInverter(t).NewSungrow()
+	require.NoError(t, sg.Connect())
+	t.Cleanup(sg.Close)
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				var s redgiant.State
+				err := sg.Send("state", nil, &s)
+				if err == nil {
+					continue
+				}
+				// requests sent after Close fail without reconnecting
+				var de *redgiant.SungrowDisconnectedError
+				assert.True(t, errors.As(err, &de), err)
+			}
+		}()
+	}
+	sg.Close()
+	wg.Wait()
+
+	assert.False(t, sg.IsConnected())
+}
@@ -0,0 +1,27 @@
+package redgiant
+
+import (
+	"fmt"
+	"net/http"
+	"syscall"
+	"testing"
+
+	"github.com/pmeier/redgiant/internal/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSungrowDisconnectedError(t *testing.T) {
+	cause := fmt.Errorf("read: %w", syscall.ECONNRESET)
+	err := errors.Wrap(wrapSungrowDisconnectedError(cause), errors.WithContext(errors.Context{"service": "real"}))
+
+	var de *SungrowDisconnectedError
+	require.True(t, errors.As(err, &de))
+	assert.True(t, errors.Is(err, syscall.ECONNRESET))
+	assert.True(t, errors.IsTransient(err))
+	assert.Equal(t, errors.InverterFault, errors.FaultOf(err))
+
+	var rge *errors.RedgiantError
+	require.True(t, errors.As(err, &rge))
+	assert.Equal(t, http.StatusServiceUnavailable, rge.HTTPCode())
+}
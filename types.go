@@ -2,6 +2,7 @@ package redgiant
 
 import (
 	"fmt"
+	"time"
 )
 
 type intBool bool
@@ -111,11 +112,24 @@ func (sd *sungrowDevice) ToRedgiant() Device {
 	}
 }
 
+// Sample describes where and when a measurement was read.
+type Sample struct {
+	DeviceID int `json:"deviceID"`
+	// Service is the service of the inverter the measurement was read with,
+	// e.g. "real", "real_battery" or "direct".
+	Service   string    `json:"service"`
+	SampledAt time.Time `json:"sampledAt"`
+	// InverterSerial is the serial number of the inverter the device is
+	// connected to. It is empty if it could not be read.
+	InverterSerial string `json:"inverterSerial"`
+}
+
 type RealMeasurement struct {
 	I18NCode string `json:"i18nCode"`
 	Name     string `json:"name"`
 	Value    string `json:"value"`
 	Unit     string `json:"unit"`
+	Sample
 }
 
 type sungrowRealMeasurement struct {
@@ -140,6 +154,7 @@ type DirectMeasurement struct {
 	VoltageUnit string  `json:"voltageUnit"`
 	Current     float32 `json:"current"`
 	CurrentUnit string  `json:"currentUnit"`
+	Sample
 }
 
 type sungrowDirectMeasurement struct {
@@ -160,3 +175,33 @@ func (sdm *sungrowDirectMeasurement) ToRedgiant() DirectMeasurement {
 		CurrentUnit: sdm.CurrentUnit,
 	}
 }
+
+// DeviceSnapshot holds the measurements of a device. Devices that do not
+// provide a kind of data have no measurements of that kind.
+type DeviceSnapshot struct {
+	Device Device              `json:"device"`
+	Real   []RealMeasurement   `json:"real"`
+	Direct []DirectMeasurement `json:"direct"`
+	// Errors are the services that could not be read. The measurements of the
+	// other services are still included.
+	Errors []SnapshotError `json:"errors,omitempty"`
+}
+
+// SnapshotError describes why a service of a device could not be read.
+type SnapshotError struct {
+	// Service is empty if the device could not be read at all, e.g. since its
+	// type is unknown.
+	Service string `json:"service,omitempty"`
+	// Code is the code of the error as sent in problem details, if any.
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// Snapshot holds the data of all devices read in one pass. The measurements
+// carry the time they were read themselves, Time is when the pass started.
+type Snapshot struct {
+	Time    time.Time        `json:"time"`
+	About   About            `json:"about"`
+	State   State            `json:"state"`
+	Devices []DeviceSnapshot `json:"devices"`
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -41,3 +42,31 @@ func TestIntBoolUnmarshalJSON(t *testing.T) {
 		)
 	}
 }
+
+func TestRealMeasurementMarshalJSON(t *testing.T) {
+	m := RealMeasurement{
+		I18NCode: "I18N_COMMON_BATTERY_SOC",
+		Name:     "Battery Level (SOC)",
+		Value:    "87.5",
+		Unit:     "%",
+		Sample: Sample{
+			DeviceID:       1,
+			Service:        "real_battery",
+			SampledAt:      time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+			InverterSerial: "A2340000000",
+		},
+	}
+
+	j, err := json.Marshal(m)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"i18nCode": "I18N_COMMON_BATTERY_SOC",
+		"name": "Battery Level (SOC)",
+		"value": "87.5",
+		"unit": "%",
+		"deviceID": 1,
+		"service": "real_battery",
+		"sampledAt": "2024-06-01T12:00:00Z",
+		"inverterSerial": "A2340000000"
+	}`, string(j))
+}